@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	drugbank "github.com/iz4vve/drugbank-dataset-parser"
+)
+
+// diffReleases compares the datasets at oldPath and newPath
+// and writes their diff to out, as text or as json
+func diffReleases(oldPath, newPath string, workers int, asJSON bool, out io.Writer) error {
+	old, err := drugbank.OpenInput(oldPath)
+	if err != nil {
+		return err
+	}
+	defer old.Close()
+	new, err := drugbank.OpenInput(newPath)
+	if err != nil {
+		return err
+	}
+	defer new.Close()
+
+	diff, err := drugbank.Compare(old, new, workers)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	}
+	writeDiff(out, diff)
+	return nil
+}
+
+// maxValueLength is the length past which changed values are cut in text diffs
+const maxValueLength = 80
+
+// maxRowLength is the length past which the json of rows is cut in text diffs
+const maxRowLength = 160
+
+// writeDiff writes diff as text: a line per added (+), removed (-)
+// and changed (~) drug, followed by the totals per table. The fields
+// of changed drugs are listed with their values, their tables with the
+// rows added and removed.
+func writeDiff(w io.Writer, diff *drugbank.Diff) {
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", releaseName(diff.Old), releaseName(diff.New))
+	for _, d := range diff.Added {
+		fmt.Fprintf(w, "+ %s %s\n", d.ID, d.Name)
+	}
+	for _, d := range diff.Removed {
+		fmt.Fprintf(w, "- %s %s\n", d.ID, d.Name)
+	}
+	for _, d := range diff.Changed {
+		fmt.Fprintf(w, "~ %s %s\n", d.ID, d.Name)
+		for _, field := range d.Fields {
+			fmt.Fprintf(w, "    %s: %s -> %s\n", field.Field, shorten(field.Old), shorten(field.New))
+		}
+		tables := make([]string, 0, len(d.Tables))
+		for table := range d.Tables {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+		for _, table := range tables {
+			rows := d.Tables[table]
+			fmt.Fprintf(w, "    %s: +%d -%d\n", table, rows.Added, rows.Removed)
+			for _, row := range rows.RemovedRows {
+				fmt.Fprintf(w, "      - %s\n", shortenRow(row))
+			}
+			for _, row := range rows.AddedRows {
+				fmt.Fprintf(w, "      + %s\n", shortenRow(row))
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d drugs added, %d removed, %d changed, %d unchanged\n",
+		len(diff.Added), len(diff.Removed), len(diff.Changed), diff.Unchanged)
+	for _, table := range sortedTables(diff.Tables) {
+		fmt.Fprintf(w, "%10s %10s  %s\n",
+			fmt.Sprintf("+%d", diff.Tables[table].Added), fmt.Sprintf("-%d", diff.Tables[table].Removed), table)
+	}
+}
+
+// releaseName formats a release for the header of a text diff
+func releaseName(release drugbank.Release) string {
+	if release.Version == "" {
+		return "unknown release"
+	}
+	name := "DrugBank " + release.Version
+	if release.ExportedOn != "" {
+		name += " (" + release.ExportedOn + ")"
+	}
+	return name
+}
+
+// shorten quotes a value, cut to maxValueLength characters
+func shorten(value string) string {
+	value = strings.TrimSpace(value)
+	if runes := []rune(value); len(runes) > maxValueLength {
+		value = string(runes[:maxValueLength]) + "..."
+	}
+	return fmt.Sprintf("%q", value)
+}
+
+// shortenRow cuts the json of a row to maxRowLength characters
+func shortenRow(row []byte) string {
+	if runes := []rune(string(row)); len(runes) > maxRowLength {
+		return string(runes[:maxRowLength]) + "..."
+	}
+	return string(row)
+}
+
+func sortedTables(tables map[string]*drugbank.TableChange) []string {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,599 @@
+// Command drugbank parses the drugbank dataset xml file
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docopt/docopt-go"
+
+	drugbank "github.com/iz4vve/drugbank-dataset-parser"
+	"github.com/iz4vve/drugbank-dataset-parser/parquet"
+	"github.com/iz4vve/drugbank-dataset-parser/postgres"
+	"github.com/iz4vve/drugbank-dataset-parser/sqlite"
+	"github.com/iz4vve/drugbank-dataset-parser/tigergraph"
+)
+
+var version = "0.1"
+
+func main() {
+	usage := `Drugbank parser.
+
+	Usage:
+		drugbank parse <path> <outputdir> [--format=<format>] [--compression=<codec>] [--workers=<n>] [--progress=<mode>] [--continue-on-error] [--since=<date> --base=<dir>]
+		drugbank process <path> <outputdir> <host> [--user=<user>] [--password=<password>] [--job=<file>] [--batch=<n>] [--workers=<n>] [--progress=<mode>]
+		drugbank export sqlite <path> <db> [--workers=<n>] [--progress=<mode>] [--continue-on-error]
+		drugbank export postgres <path> <outputdir> [--dsn=<dsn>] [--workers=<n>] [--progress=<mode>] [--continue-on-error]
+		drugbank export rdf <path> <file> [--workers=<n>] [--progress=<mode>] [--continue-on-error]
+		drugbank schema [<outputdir>] [--target=<target>] [--graph=<graph>]
+		drugbank diff <old> <new> [--json] [--workers=<n>]
+		drugbank validate <path> [--xsd=<file>] [--json] [--workers=<n>]
+		drugbank -h | --help
+		drugbank --version
+
+	Options:
+		--format=<format>  	Output format, json, csv, tsv, neo4j or parquet [default: json].
+		--compression=<codec>  	Parquet compression, uncompressed, snappy, gzip, lz4 or zstd [default: snappy].
+		--workers=<n>  		Number of decoding workers, 0 for one per CPU [default: 0].
+		--progress=<mode>  		Progress report on stderr, bar, json or none [default: bar].
+		--continue-on-error  	Log drugs that cannot be decoded to rejects.json and keep going.
+		--since=<date>  		Only parse the drugs updated on or after the date (YYYY-MM-DD) and merge them into --base.
+		--base=<dir>  			Output of a previous parse, in the same format, to merge the updated drugs into.
+		--user=<user>  			Username for Tigergraph instance.
+		--password=<password>  		Password for Tigergraph instance.
+		--job=<file>  			Gsql script defining the loading job [default: load_schema.gsql].
+		--batch=<n>  			Number of records posted per request [default: 10000].
+		--target=<target>  		Database to generate the schema for, tigergraph or neo4j [default: tigergraph].
+		--graph=<graph>  		Name of the graph [default: drugbank].
+		--dsn=<dsn>  			PostgreSQL connection string to load the tables into.
+		--json  			Write the diff or validation report as json.
+		--xsd=<file>  			DrugBank schema definition to validate against, instead of the built-in rules.
+		-h --help     			Show this screen.
+		--version    	 		Show version.`
+
+	arguments, _ := docopt.ParseArgs(usage, os.Args[1:], version)
+	defer TimeTrack("main", time.Now())
+
+	if p, _ := arguments.Bool("parse"); p {
+		path, _ := arguments.String("<path>")
+		outputdir, _ := arguments.String("<outputdir>")
+		var options parseOptions
+		options.format, _ = arguments.String("--format")
+		options.compression, _ = arguments.String("--compression")
+		options.workers, _ = arguments.Int("--workers")
+		options.progress, _ = arguments.String("--progress")
+		options.continueOnError, _ = arguments.Bool("--continue-on-error")
+		options.since, _ = arguments.String("--since")
+		options.base, _ = arguments.String("--base")
+		fmt.Printf("Parsing %s to %s\n", path, outputdir)
+		run, err := parse(path, outputdir, options)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := run.Write(); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Done.")
+		os.Exit(0)
+	}
+
+	if p, _ := arguments.Bool("process"); p {
+		path, _ := arguments.String("<path>")
+		outputdir, _ := arguments.String("<outputdir>")
+		host, _ := arguments.String("<host>")
+		options := parseOptions{format: "csv"}
+		options.workers, _ = arguments.Int("--workers")
+		options.progress, _ = arguments.String("--progress")
+		var upload uploadOptions
+		upload.user, _ = arguments.String("--user")
+		upload.password, _ = arguments.String("--password")
+		upload.job, _ = arguments.String("--job")
+		upload.batch, _ = arguments.Int("--batch")
+		fmt.Printf("Parsing %s to %s...\n", path, outputdir)
+		run, err := parse(path, outputdir, options)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Done parsing")
+		fmt.Printf("Uploading data to %s...\n", host)
+		if err := uploadTables(outputdir, host, upload); err != nil {
+			log.Fatal(err)
+		}
+		if err := run.Write(); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	if e, _ := arguments.Bool("export"); e {
+		path, _ := arguments.String("<path>")
+		var options parseOptions
+		options.workers, _ = arguments.Int("--workers")
+		options.progress, _ = arguments.String("--progress")
+		options.continueOnError, _ = arguments.Bool("--continue-on-error")
+		var output string
+		if s, _ := arguments.Bool("sqlite"); s {
+			options.format = "sqlite"
+			output, _ = arguments.String("<db>")
+		}
+		if r, _ := arguments.Bool("rdf"); r {
+			output, _ = arguments.String("<file>")
+			options.format = "turtle"
+			if filepath.Ext(output) == ".nt" {
+				options.format = "ntriples"
+			}
+		}
+		postgresExport, _ := arguments.Bool("postgres")
+		if postgresExport {
+			options.format = "tsv"
+			output, _ = arguments.String("<outputdir>")
+		}
+		fmt.Printf("Exporting %s to %s\n", path, output)
+		run, err := parse(path, output, options)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if postgresExport {
+			dsn, _ := arguments.String("--dsn")
+			scripts, err := exportPostgres(output, dsn)
+			if err != nil {
+				log.Fatal(err)
+			}
+			run.files = append(run.files, scripts...)
+		}
+		if err := run.Write(); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Done.")
+		os.Exit(0)
+	}
+
+	if s, _ := arguments.Bool("schema"); s {
+		outputdir, _ := arguments.String("<outputdir>")
+		if outputdir == "" {
+			outputdir = "."
+		}
+		target, _ := arguments.String("--target")
+		graph, _ := arguments.String("--graph")
+		if err := writeSchema(outputdir, target, graph); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	if d, _ := arguments.Bool("diff"); d {
+		oldPath, _ := arguments.String("<old>")
+		newPath, _ := arguments.String("<new>")
+		workers, _ := arguments.Int("--workers")
+		asJSON, _ := arguments.Bool("--json")
+		if err := diffReleases(oldPath, newPath, workers, asJSON, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	if v, _ := arguments.Bool("validate"); v {
+		path, _ := arguments.String("<path>")
+		xsd, _ := arguments.String("--xsd")
+		workers, _ := arguments.Int("--workers")
+		asJSON, _ := arguments.Bool("--json")
+		result, err := validate(path, xsd, workers)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := writeValidation(os.Stdout, result, asJSON); err != nil {
+			log.Fatal(err)
+		}
+		if !result.Valid() {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+}
+
+// writeSchema generates the schema of the output tables for target in outputdir
+func writeSchema(outputdir, target, graph string) error {
+	type schemaFile struct {
+		name  string
+		write func(io.Writer) error
+	}
+	var files []schemaFile
+	switch target {
+	case "tigergraph":
+		files = []schemaFile{
+			{"create_schema.gsql", func(w io.Writer) error {
+				return tigergraph.WriteSchema(w, drugbank.Tables, graph)
+			}},
+			{"load_schema.gsql", func(w io.Writer) error {
+				return tigergraph.WriteLoadingJob(w, drugbank.Tables, graph, "load_drugs", "csv")
+			}},
+		}
+	case "neo4j":
+		files = []schemaFile{
+			{"constraints.cypher", func(w io.Writer) error {
+				return drugbank.WriteNeo4jConstraints(w, drugbank.Tables)
+			}},
+		}
+	default:
+		return fmt.Errorf("unknown schema target %q", target)
+	}
+	if err := os.MkdirAll(outputdir, 0770); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := drugbank.WriteFile(filepath.Join(outputdir, f.name), 0666, f.write); err != nil {
+			return err
+		}
+		fmt.Println("Wrote", filepath.Join(outputdir, f.name))
+	}
+	return nil
+}
+
+// exportPostgres writes the scripts loading the tsv tables in outputdir
+// into PostgreSQL and, given a connection string, loads them.
+// It returns the paths of the scripts.
+func exportPostgres(outputdir, dsn string) ([]string, error) {
+	scripts := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"schema.sql", func(w io.Writer) error {
+			return postgres.WriteSchema(w, drugbank.Tables)
+		}},
+		{"constraints.sql", func(w io.Writer) error {
+			return postgres.WriteConstraints(w, drugbank.Tables)
+		}},
+		{"load.sql", func(w io.Writer) error {
+			return postgres.WriteLoadScript(w, drugbank.Tables, outputdir)
+		}},
+	}
+	var paths []string
+	for _, script := range scripts {
+		path := filepath.Join(outputdir, script.name)
+		if err := drugbank.WriteFile(path, 0666, script.write); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	if dsn == "" {
+		fmt.Printf("Load the tables with: cd %s && psql -f load.sql\n", outputdir)
+		return paths, nil
+	}
+	defer TimeTrack("load", time.Now())
+	rows, err := postgres.Load(context.Background(), dsn, outputdir, drugbank.Tables)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range drugbank.Tables {
+		if n, ok := rows[table.Name]; ok {
+			fmt.Printf("%s: %d rows\n", table.Name, n)
+		}
+	}
+	return paths, nil
+}
+
+// uploadOptions tunes how the parsed tables are sent to TigerGraph
+type uploadOptions struct {
+	user, password string
+	job            string // gsql script defining the loading job
+	batch          int    // records posted per request
+}
+
+// uploadTables posts the csv tables in directory to the loading job
+// defined in options.job, reporting what was accepted for every file
+func uploadTables(directory, host string, options uploadOptions) error {
+	defer TimeTrack("upload", time.Now())
+	script, err := os.Open(options.job)
+	if err != nil {
+		return err
+	}
+	job, err := tigergraph.ParseJob(script)
+	script.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %v", options.job, err)
+	}
+
+	client := tigergraph.NewClient(host, job.Graph)
+	client.User = options.user
+	client.Password = options.password
+	if options.batch > 0 {
+		client.BatchSize = options.batch
+	}
+
+	for _, f := range job.Files {
+		path := filepath.Join(directory, filepath.Base(f.Path))
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			fmt.Printf("%s: not found, skipped\n", path)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		stats, err := client.Load(job.Name, f.Variable, file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		fmt.Printf("%s: %d lines accepted, %d rejected%s\n",
+			path, stats.ValidLines, stats.RejectedLines, objectCounts(stats))
+	}
+	return nil
+}
+
+// objectCounts formats the vertices and edges created from a file
+func objectCounts(stats tigergraph.Stats) string {
+	var counts []string
+	for _, objects := range []map[string]int{stats.Vertices, stats.Edges} {
+		names := make([]string, 0, len(objects))
+		for name := range objects {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			counts = append(counts, fmt.Sprintf("%s: %d", name, objects[name]))
+		}
+	}
+	if len(counts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(counts, ", ") + ")"
+}
+
+// parseOptions tunes how parse reads the dataset
+type parseOptions struct {
+	format          string // output format, json, csv, tsv, neo4j, parquet, sqlite, turtle or ntriples
+	compression     string // compression of parquet files
+	workers         int    // number of decoding workers, 0 for one per CPU
+	continueOnError bool   // reject undecodable drugs instead of failing
+	progress        string // progress report, bar, json or none
+	since           string // only merge the drugs updated on or after this date into base
+	base            string // output of a previous run
+}
+
+// newWriter returns the TableWriter writing the output tables in the
+// format of options to output, which is a directory except for databases
+func newWriter(output string, options parseOptions) (drugbank.TableWriter, error) {
+	switch options.format {
+	case "json":
+		return drugbank.NewJSONWriter(output), nil
+	case "csv":
+		return drugbank.NewCSVWriter(output), nil
+	case "tsv":
+		return drugbank.NewTSVWriter(output), nil
+	case "neo4j":
+		return drugbank.NewNeo4jWriter(output), nil
+	case "parquet":
+		return parquet.NewWriter(output, options.compression)
+	case "sqlite":
+		return sqlite.Create(output, drugbank.Tables)
+	case "turtle":
+		return drugbank.NewRDFWriter(output, drugbank.Turtle)
+	case "ntriples":
+		return drugbank.NewRDFWriter(output, drugbank.NTriples)
+	default:
+		return nil, fmt.Errorf("unknown output format %q", options.format)
+	}
+}
+
+// outputDir returns the directory the output of format is written to,
+// along with the rejected drugs
+func outputDir(format, output string) string {
+	switch format {
+	case "sqlite", "turtle", "ntriples":
+		return filepath.Dir(output)
+	}
+	return output
+}
+
+// parse decodes the xml file at path and writes the output tables to output.
+// It returns the manifest of the run, to be written once the run is over.
+func parse(path, output string, options parseOptions) (run *manifest, err error) {
+	start := time.Now()
+	defer TimeTrack("parse", start)
+	input, err := drugbank.OpenInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+	progress, err := newProgress(options.progress, os.Stderr, input)
+	if err != nil {
+		return nil, err
+	}
+	pipeline := drugbank.NewPipeline(input, options.workers)
+	pipeline.Coverage = drugbank.NewCoverage()
+
+	outputdir := outputDir(options.format, output)
+	if err := os.MkdirAll(outputdir, 0770); err != nil {
+		return nil, err
+	}
+	// updates only collect the updated drugs, merged once all are read
+	var changes *drugbank.Changes
+	var writer drugbank.TableWriter
+	if options.since != "" {
+		if options.base == "" {
+			return nil, errors.New("--since needs the --base output to merge the updated drugs into")
+		}
+		if changes, err = drugbank.NewChanges(options.since, options.format); err != nil {
+			return nil, err
+		}
+		defer changes.Close()
+	} else {
+		if writer, err = newWriter(output, options); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if closeErr := writer.Close(); err == nil {
+				err = closeErr
+			}
+			if lister, ok := writer.(drugbank.FileLister); ok && run != nil {
+				run.files = append(run.files, lister.Files()...)
+			}
+		}()
+	}
+
+	if options.continueOnError {
+		rejects := newRejectLog(filepath.Join(outputdir, "rejects.json"))
+		defer func() {
+			if closeErr := rejects.Close(); err == nil {
+				err = closeErr
+			}
+			if rejects.count > 0 {
+				fmt.Printf("%d drugs rejected, see %s\n", rejects.count, rejects.path)
+				if run != nil {
+					run.files = append(run.files, rejects.path)
+				}
+			}
+		}()
+		pipeline.Reject = func(decodeErr *drugbank.DecodeError, element []byte) error {
+			log.Println(decodeErr)
+			progress.Reject(decodeErr)
+			return rejects.Write(decodeErr, element)
+		}
+	}
+
+	err = pipeline.Run(func(record *drugbank.Record) error {
+		// invalid values are left empty, the drug is kept
+		for _, decodeErr := range record.Errors {
+			log.Println(decodeErr)
+		}
+		if changes != nil {
+			changes.Add(record.Drug)
+			progress.Record(record)
+			return nil
+		}
+		for _, row := range record.Rows {
+			if err := writer.Write(row.Table, row.Value); err != nil {
+				return err
+			}
+		}
+		progress.Record(record)
+		return nil
+	})
+	progress.Stop(err)
+	if err != nil {
+		return nil, err
+	}
+	writeCoverage(os.Stdout, pipeline.Coverage)
+
+	run = &manifest{
+		Tool:      "drugbank",
+		Version:   version,
+		StartedAt: start,
+		Input:     manifestInput{Path: path, Bytes: input.Size, Compressed: input.Compressed},
+		DrugBank:  pipeline.Release(),
+		Format:    options.format,
+		Drugs:     progress.drugs,
+		Rejected:  progress.rejected,
+		Tables:    map[string]manifestTable{},
+		Coverage: manifestCoverage{
+			Score:   pipeline.Coverage.Score(),
+			Unbound: pipeline.Coverage.Unbound(),
+		},
+		dir: outputdir,
+	}
+	if run.Input.SHA256, err = input.SHA256(); err != nil {
+		return nil, err
+	}
+	if input.Size < 0 {
+		// read from stdin, to the end by now
+		run.Input.Bytes = input.Offset()
+	}
+	if changes != nil {
+		return run, mergeChanges(run, changes, options)
+	}
+	for table, rows := range progress.tables {
+		run.Tables[table] = manifestTable{Rows: rows}
+	}
+	return run, nil
+}
+
+// mergeChanges merges the drugs updated since options.since into the
+// output of a previous run, recording the rows of every table in run
+func mergeChanges(run *manifest, changes *drugbank.Changes, options parseOptions) error {
+	defer TimeTrack("merge", time.Now())
+	fmt.Printf("Merging %d drugs updated since %s into %s\n", changes.Updated(), changes.Since, options.base)
+	updates, err := changes.Merge(options.base, run.dir)
+	if err != nil {
+		return err
+	}
+	run.Since, run.Base = changes.Since.String(), options.base
+	for _, table := range drugbank.Tables {
+		update, ok := updates[table.Name]
+		if !ok {
+			continue
+		}
+		run.Tables[table.Name] = manifestTable{Rows: update.Rows, Added: update.Added, Removed: update.Removed}
+		run.files = append(run.files, update.Files...)
+		if update.Added > 0 || update.Removed > 0 {
+			fmt.Printf("%10s %10s  %s\n", fmt.Sprintf("+%d", update.Added), fmt.Sprintf("-%d", update.Removed), table.Name)
+		}
+	}
+	return nil
+}
+
+// rejectLog records the drugs that could not be decoded as json lines.
+// The file is only created once the first drug is rejected.
+type rejectLog struct {
+	path    string
+	file    *os.File
+	encoder *json.Encoder
+	count   int
+}
+
+func newRejectLog(path string) *rejectLog {
+	return &rejectLog{path: path}
+}
+
+// Write logs a rejected drug along with its xml
+func (r *rejectLog) Write(decodeErr *drugbank.DecodeError, element []byte) error {
+	if r.file == nil {
+		file, err := os.Create(r.path)
+		if err != nil {
+			return err
+		}
+		r.file = file
+		r.encoder = json.NewEncoder(file)
+	}
+	r.count++
+	return r.encoder.Encode(struct {
+		Index  int    `json:"index"`
+		Offset int64  `json:"offset"`
+		Path   string `json:"path"`
+		ID     string `json:"drugbank-id"`
+		Error  string `json:"error"`
+		XML    string `json:"xml"`
+	}{
+		decodeErr.Index,
+		decodeErr.Offset,
+		decodeErr.Path,
+		decodeErr.ID,
+		decodeErr.Err.Error(),
+		string(element),
+	})
+}
+
+// Close closes the log file, if any drug was rejected
+func (r *rejectLog) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
+
+// TimeTrack tracks the execution time of a function,
+// recorded as a phase of the run in the manifest
+func TimeTrack(name string, start time.Time) {
+	elapsed := time.Since(start)
+	phases = append(phases, phase{name, elapsed.Seconds()})
+	fmt.Printf("%s took %.2f seconds\n", name, elapsed.Seconds())
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the command instead of the tests when invoked by drugbank
+func TestMain(m *testing.M) {
+	if os.Getenv("DRUGBANK_RUN_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCommand runs the command with args, returning its exit code and output
+func runCommand(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "DRUGBANK_RUN_MAIN=1")
+	output, err := cmd.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), string(output)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return 0, string(output)
+}
+
+// brokenDrug is a drug that can be read but not decoded
+const brokenDrug = `<drug type="small molecule" created="2005-06-13" updated="2019-01-01">
+  <drugbank-id primary="true">DB99999</drugbank-id>
+  <name>Broken</name>
+  <prices><price><description>Broken</description><cost currency="USD">not a number</cost></price></prices>
+</drug>
+`
+
+// reject is a line of rejects.json
+type reject struct {
+	Index  int    `json:"index"`
+	Offset int64  `json:"offset"`
+	Path   string `json:"path"`
+	ID     string `json:"drugbank-id"`
+	Error  string `json:"error"`
+	XML    string `json:"xml"`
+}
+
+func TestParseErrors(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", "drugbank.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture := string(data)
+	end := strings.LastIndex(fixture, "</drugbank>")
+	broken := fixture[:end] + brokenDrug + fixture[end:]
+	truncated := fixture[:strings.Index(fixture, "<name>Dornase")]
+	// invalid patent dates are left empty, keeping the drug
+	invalidDate := strings.Replace(fixture, "<approved>1993-01-19</approved>", "<approved>19/01/1993</approved>", 1)
+
+	tests := []struct {
+		name            string
+		document        string
+		continueOnError bool
+		exit            int
+		rejects         []reject
+	}{
+		{name: "valid", document: fixture},
+		{name: "invalid drug", document: broken, exit: 1},
+		{
+			name:            "invalid drug, continue on error",
+			document:        broken,
+			continueOnError: true,
+			rejects: []reject{{
+				Index:  3,
+				Offset: int64(end),
+				Path:   "drugbank/drug/prices/price/cost",
+				ID:     "DB99999",
+			}},
+		},
+		{name: "invalid patent date", document: invalidDate},
+		{name: "truncated", document: truncated, exit: 1},
+		{name: "truncated, continue on error", document: truncated, continueOnError: true, exit: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "drugbank")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "drugbank.xml")
+			if err := ioutil.WriteFile(path, []byte(test.document), 0660); err != nil {
+				t.Fatal(err)
+			}
+			output := filepath.Join(dir, "output")
+			args := []string{"parse", path, output, "--progress=none"}
+			if test.continueOnError {
+				args = append(args, "--continue-on-error")
+			}
+
+			exit, log := runCommand(t, args...)
+			if exit != test.exit {
+				t.Fatalf("exit code %d, want %d\n%s", exit, test.exit, log)
+			}
+			if exit != 0 && strings.Contains(log, "Done.") {
+				t.Errorf("failed run reported as done\n%s", log)
+			}
+
+			data, err := ioutil.ReadFile(filepath.Join(output, "rejects.json"))
+			if os.IsNotExist(err) && len(test.rejects) == 0 {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			if len(lines) != len(test.rejects) {
+				t.Fatalf("%d drugs rejected, want %d", len(lines), len(test.rejects))
+			}
+			for i, line := range lines {
+				var got reject
+				if err := json.Unmarshal([]byte(line), &got); err != nil {
+					t.Fatal(err)
+				}
+				if got.Error == "" || !strings.Contains(got.XML, "DB99999") {
+					t.Errorf("reject without error or xml: %s", line)
+				}
+				got.Error, got.XML = "", ""
+				if got != test.rejects[i] {
+					t.Errorf("rejected %+v, want %+v", got, test.rejects[i])
+				}
+			}
+		})
+	}
+}
+
+func TestManifestFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drugbank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fixture := filepath.Join("..", "..", "testdata", "drugbank.xml")
+	// files of the output directory not written by the run
+	for _, name := range []string{"notes.txt", "old.db", "drugs_v4.csv"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0660); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		tables bool     // whether tables have files of their own
+		files  []string // not holding a single table
+	}{
+		{"csv", []string{"parse", fixture, dir, "--format=csv"}, true, []string{}},
+		{"sqlite", []string{"export", "sqlite", fixture, filepath.Join(dir, "drugbank.db")}, false, []string{"drugbank.db"}},
+		{"rdf", []string{"export", "rdf", fixture, filepath.Join(dir, "drugbank.ttl")}, false, []string{"drugbank.ttl"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := append(test.args, "--progress=none")
+			exit, log := runCommand(t, args...)
+			if strings.Contains(log, "sqlite_fts5") {
+				t.Skip("built without FTS5")
+			}
+			if exit != 0 {
+				t.Fatalf("exit code %d\n%s", exit, log)
+			}
+			data, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var m manifest
+			if err := json.Unmarshal(data, &m); err != nil {
+				t.Fatal(err)
+			}
+			var files []string
+			for _, file := range m.Files {
+				files = append(files, file.Path)
+				if len(file.SHA256) != 64 || file.Bytes == 0 {
+					t.Errorf("%s: size %d, checksum %q", file.Path, file.Bytes, file.SHA256)
+				}
+			}
+			if strings.Join(files, ",") != strings.Join(test.files, ",") {
+				t.Errorf("files %v, want %v", files, test.files)
+			}
+			for name, table := range m.Tables {
+				if test.tables != (table.File != "") {
+					t.Errorf("table %s in file %q", name, table.File)
+				}
+				if table.File != "" && table.File != name+".csv" && table.File != name+".fasta" {
+					t.Errorf("table %s in file %s", name, table.File)
+				}
+			}
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	tests := []struct {
+		target   string
+		files    []string
+		contains string
+	}{
+		{"tigergraph", []string{"create_schema.gsql", "load_schema.gsql"}, "CREATE GRAPH drugbank"},
+		{"neo4j", []string{"constraints.cypher"}, "CREATE CONSTRAINT"},
+	}
+	for _, test := range tests {
+		t.Run(test.target, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "drugbank")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			exit, log := runCommand(t, "schema", dir, "--target="+test.target)
+			if exit != 0 {
+				t.Fatalf("exit code %d\n%s", exit, log)
+			}
+			var written string
+			for _, name := range test.files {
+				data, err := ioutil.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("%v\n%s", err, log)
+				}
+				written += string(data)
+			}
+			if !strings.Contains(written, test.contains) {
+				t.Errorf("%v hold no %q", test.files, test.contains)
+			}
+		})
+	}
+
+	dir, err := ioutil.TempDir("", "drugbank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if exit, log := runCommand(t, "schema", dir, "--target=oracle"); exit == 0 {
+		t.Errorf("unknown target accepted\n%s", log)
+	}
+}
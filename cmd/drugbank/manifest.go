@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	drugbank "github.com/iz4vve/drugbank-dataset-parser"
+)
+
+// manifest records what a run produced and from which input,
+// written to manifest.json along with the output
+type manifest struct {
+	Tool      string                   `json:"tool"`
+	Version   string                   `json:"version"`
+	StartedAt time.Time                `json:"started-at"`
+	Input     manifestInput            `json:"input"`
+	DrugBank  drugbank.Release         `json:"drugbank"`
+	Format    string                   `json:"format"`
+	Since     string                   `json:"since,omitempty"` // of an update merged into Base
+	Base      string                   `json:"base,omitempty"`
+	Drugs     int                      `json:"drugs"`
+	Rejected  int                      `json:"rejected"`
+	Tables    map[string]manifestTable `json:"tables"`
+	Files     []manifestFile           `json:"files"` // files not holding a single table
+	Coverage  manifestCoverage         `json:"coverage"`
+	Phases    []phase                  `json:"phases"`
+
+	dir   string
+	files []string // created by the run
+}
+
+type manifestInput struct {
+	Path       string `json:"path"`
+	Bytes      int64  `json:"bytes"`
+	Compressed bool   `json:"compressed"`
+	SHA256     string `json:"sha256"`
+}
+
+// manifestTable lists the rows written to a table and,
+// if it has a file of its own, its checksum
+type manifestTable struct {
+	Rows    int    `json:"rows"`
+	Added   int    `json:"added,omitempty"` // by an update
+	Removed int    `json:"removed,omitempty"`
+	File    string `json:"file,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+}
+
+// manifestCoverage records the share of the xml bound to Drug
+// and the elements and attributes dropped
+type manifestCoverage struct {
+	Score   float64                `json:"score"`
+	Unbound []drugbank.UnboundPath `json:"unbound"`
+}
+
+type manifestFile struct {
+	Path   string `json:"path"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// phase is the wall time of a phase of the run, as tracked by TimeTrack
+type phase struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"seconds"`
+}
+
+// phases lists the phases tracked so far
+var phases []phase
+
+// Write computes the checksums of the files created by the run
+// and writes the manifest next to them
+func (m *manifest) Write() error {
+	if err := m.checksums(); err != nil {
+		return err
+	}
+	m.Phases = phases
+	return drugbank.WriteFile(filepath.Join(m.dir, "manifest.json"), 0666, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(m)
+	})
+}
+
+// checksums assigns the files created by the run to their tables. Files
+// not holding a single table, such as the delta files of an update or a
+// database, are listed with their path relative to the output directory.
+func (m *manifest) checksums() error {
+	defer TimeTrack("checksums", time.Now())
+	m.Files = []manifestFile{}
+	for _, path := range m.files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(m.dir, path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(rel, filepath.Ext(rel))
+		if table, ok := m.Tables[name]; ok && table.File == "" {
+			table.File, table.SHA256 = rel, sum
+			m.Tables[name] = table
+			continue
+		}
+		m.Files = append(m.Files, manifestFile{filepath.ToSlash(rel), info.Size(), sum})
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return nil
+}
+
+// fileSHA256 returns the hex encoded SHA-256 checksum of the file at path
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	drugbank "github.com/iz4vve/drugbank-dataset-parser"
+)
+
+// progress reports how far parse got through its input in a single pass:
+// the share of the file read, the drugs decoded and their rate, the time
+// left and the rows written to every table. Reports go to out, either as
+// a line redrawn in place (mode bar) or as json lines (mode json).
+type progress struct {
+	mode     string
+	out      io.Writer
+	input    *drugbank.Input
+	interval time.Duration // between two reports
+	start    time.Time
+	reported time.Time
+
+	offset   int64 // bytes of the file processed
+	drugs    int
+	rejected int
+	rows     int
+	tables   map[string]int
+	order    []string // tables in order of appearance
+}
+
+// progressReport is a report of the json mode
+type progressReport struct {
+	Event    string         `json:"event"` // progress, then done or failed
+	Error    string         `json:"error,omitempty"`
+	Bytes    int64          `json:"bytes"`
+	Total    int64          `json:"total"` // -1 if unknown
+	Fraction *float64       `json:"fraction"`
+	Drugs    int            `json:"drugs"`
+	Rejected int            `json:"rejected"`
+	Rate     float64        `json:"drugs-per-second"`
+	Elapsed  float64        `json:"elapsed-seconds"`
+	ETA      *float64       `json:"eta-seconds"`
+	Tables   map[string]int `json:"tables"`
+}
+
+// newProgress returns the progress of reading input, reported in
+// mode: bar, json or none
+func newProgress(mode string, out io.Writer, input *drugbank.Input) (*progress, error) {
+	p := &progress{
+		mode:     mode,
+		out:      out,
+		input:    input,
+		interval: 200 * time.Millisecond,
+		start:    time.Now(),
+		tables:   map[string]int{},
+	}
+	switch mode {
+	case "bar", "none":
+	case "json":
+		p.interval = time.Second
+	default:
+		return nil, fmt.Errorf("unknown progress mode %q", mode)
+	}
+	return p, nil
+}
+
+// Record accounts for a decoded drug and its rows
+func (p *progress) Record(record *drugbank.Record) {
+	p.drugs++
+	for _, row := range record.Rows {
+		if _, ok := p.tables[row.Table]; !ok {
+			p.order = append(p.order, row.Table)
+		}
+		p.tables[row.Table]++
+		p.rows++
+	}
+	p.update(record.End)
+}
+
+// Reject accounts for a drug that could not be decoded
+func (p *progress) Reject(decodeErr *drugbank.DecodeError) {
+	p.rejected++
+	p.update(decodeErr.Offset)
+}
+
+// update sets the offset reached in the xml and reports
+// the progress if the last report is old enough
+func (p *progress) update(offset int64) {
+	// offsets in the xml only match the file if it is not compressed,
+	// otherwise count the bytes read from it, which may be a bit ahead
+	if p.input.Compressed {
+		offset = p.input.Offset()
+	}
+	if offset > p.offset {
+		p.offset = offset
+	}
+	if now := time.Now(); now.Sub(p.reported) >= p.interval {
+		p.reported = now
+		p.report("progress", nil)
+	}
+}
+
+// Stop reports the final progress, once parsing ended with err
+func (p *progress) Stop(err error) {
+	if err != nil {
+		p.report("failed", err)
+		return
+	}
+	if p.input.Compressed {
+		p.offset = p.input.Offset()
+	} else if p.input.Size > 0 {
+		p.offset = p.input.Size
+	}
+	p.report("done", nil)
+}
+
+// estimate returns the drugs processed per second after elapsed seconds,
+// the share of a file of size bytes processed at offset and the seconds
+// left at the same pace. The share and the time left are nil if the size
+// is unknown, and the time left is nil until some of the file is processed.
+func estimate(elapsed float64, drugs int, offset, size int64) (rate float64, fraction, eta *float64) {
+	if elapsed > 0 {
+		rate = float64(drugs) / elapsed
+	}
+	if size > 0 {
+		f := float64(offset) / float64(size)
+		if f > 1 {
+			f = 1
+		}
+		fraction = &f
+		if f > 0 {
+			e := elapsed/f - elapsed
+			eta = &e
+		}
+	}
+	return rate, fraction, eta
+}
+
+func (p *progress) report(event string, err error) {
+	elapsed := time.Since(p.start).Seconds()
+	rate, fraction, eta := estimate(elapsed, p.drugs+p.rejected, p.offset, p.input.Size)
+
+	switch p.mode {
+	case "json":
+		report := progressReport{
+			Event:    event,
+			Bytes:    p.offset,
+			Total:    p.input.Size,
+			Fraction: fraction,
+			Drugs:    p.drugs,
+			Rejected: p.rejected,
+			Rate:     rate,
+			Elapsed:  elapsed,
+			ETA:      eta,
+			Tables:   p.tables,
+		}
+		if err != nil {
+			report.Error = err.Error()
+		}
+		json.NewEncoder(p.out).Encode(report)
+	case "bar":
+		var line []string
+		if fraction != nil {
+			const width = 30
+			done := int(*fraction * width)
+			line = append(line, fmt.Sprintf("[%s%s] %5.1f%%",
+				strings.Repeat("=", done), strings.Repeat(" ", width-done), *fraction*100))
+		}
+		line = append(line, fmt.Sprintf("%d drugs", p.drugs))
+		if p.rejected > 0 {
+			line = append(line, fmt.Sprintf("%d rejected", p.rejected))
+		}
+		line = append(line, fmt.Sprintf("%.0f drugs/s", rate), fmt.Sprintf("%d rows", p.rows))
+		if eta != nil && event == "progress" {
+			line = append(line, "ETA "+(time.Duration(*eta)*time.Second).String())
+		}
+		fmt.Fprintf(p.out, "\r%s\033[K", strings.Join(line, "  "))
+		if event != "progress" {
+			fmt.Fprintln(p.out)
+		}
+		if event == "done" {
+			for _, table := range p.order {
+				fmt.Fprintf(p.out, "%10d  %s\n", p.tables[table], table)
+			}
+		}
+	}
+}
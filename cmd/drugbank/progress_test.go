@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	drugbank "github.com/iz4vve/drugbank-dataset-parser"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		name          string
+		elapsed       float64
+		drugs         int
+		offset, size  int64
+		rate          float64
+		fraction, eta float64 // -1 if nil
+	}{
+		{"quarter", 10, 100, 250, 1000, 10, 0.25, 30},
+		{"started", 10, 0, 0, 1000, 0, 0, -1},
+		{"no time", 0, 0, 0, 1000, 0, 0, -1},
+		// bytes read from compressed files may be ahead of the drugs decoded
+		{"ahead", 10, 100, 1200, 1000, 10, 1, 0},
+		{"stdin", 4, 100, 250, -1, 25, -1, -1},
+		{"empty", 4, 0, 0, 0, 0, -1, -1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rate, fraction, eta := estimate(test.elapsed, test.drugs, test.offset, test.size)
+			if rate != test.rate {
+				t.Errorf("rate %v, want %v", rate, test.rate)
+			}
+			for _, value := range []struct {
+				name string
+				got  *float64
+				want float64
+			}{
+				{"fraction", fraction, test.fraction},
+				{"eta", eta, test.eta},
+			} {
+				switch {
+				case value.got == nil && value.want != -1:
+					t.Errorf("%s unknown, want %v", value.name, value.want)
+				case value.got != nil && *value.got != value.want:
+					t.Errorf("%s %v, want %v", value.name, *value.got, value.want)
+				}
+			}
+		})
+	}
+}
+
+// readReports returns the reports written by a progress in json mode
+func readReports(t *testing.T, out *bytes.Buffer) []progressReport {
+	t.Helper()
+	var reports []progressReport
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		var report progressReport
+		if err := json.Unmarshal(scanner.Bytes(), &report); err != nil {
+			t.Fatalf("%s: %v", scanner.Text(), err)
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+func TestProgressJSON(t *testing.T) {
+	var out bytes.Buffer
+	p, err := newProgress("json", &out, &drugbank.Input{Size: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first drug is reported, the others come within the interval
+	p.Record(&drugbank.Record{End: 400, Rows: []drugbank.Row{{Table: "drugs"}, {Table: "products"}, {Table: "products"}}})
+	p.Record(&drugbank.Record{End: 800, Rows: []drugbank.Row{{Table: "drugs"}}})
+	p.Reject(&drugbank.DecodeError{Offset: 900})
+	p.Stop(nil)
+
+	reports := readReports(t, &out)
+	if len(reports) != 2 {
+		t.Fatalf("%d reports, want 2:\n%s", len(reports), out.String())
+	}
+	first, done := reports[0], reports[1]
+	if first.Event != "progress" || first.Bytes != 400 || first.Total != 1000 || first.Drugs != 1 ||
+		first.Fraction == nil || *first.Fraction != 0.4 || first.ETA == nil ||
+		first.Tables["drugs"] != 1 || first.Tables["products"] != 2 {
+		t.Errorf("first report %+v", first)
+	}
+	// the whole file is read once done
+	if done.Event != "done" || done.Bytes != 1000 || done.Drugs != 2 || done.Rejected != 1 ||
+		done.Fraction == nil || *done.Fraction != 1 || done.ETA == nil || *done.ETA != 0 ||
+		done.Tables["drugs"] != 2 || done.Error != "" {
+		t.Errorf("final report %+v", done)
+	}
+
+	// the size of stdin is unknown, and failures are reported with their error
+	out.Reset()
+	if p, err = newProgress("json", &out, &drugbank.Input{Size: -1}); err != nil {
+		t.Fatal(err)
+	}
+	p.Stop(errors.New("unexpected EOF"))
+	if line := out.String(); !strings.Contains(line, `"fraction":null`) || !strings.Contains(line, `"eta-seconds":null`) {
+		t.Errorf("report %s, want no fraction nor eta", line)
+	}
+	reports = readReports(t, &out)
+	if len(reports) != 1 || reports[0].Event != "failed" || reports[0].Error != "unexpected EOF" || reports[0].Total != -1 {
+		t.Errorf("reports %+v, want a failure", reports)
+	}
+
+	if _, err := newProgress("percent", &out, &drugbank.Input{}); err == nil {
+		t.Error("unknown progress mode accepted")
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	drugbank "github.com/iz4vve/drugbank-dataset-parser"
+)
+
+// validation is the outcome of validating a dataset
+type validation struct {
+	DrugBank  drugbank.Release       `json:"drugbank"`
+	Schema    string                 `json:"schema"` // xsd file, or builtin
+	Drugs     int                    `json:"drugs"`
+	Coverage  float64                `json:"coverage"`
+	Unknown   []drugbank.PathCount   `json:"unknown"`   // paths the schema does not allow
+	Dropped   []drugbank.UnboundPath `json:"dropped"`   // paths no field of Drug binds
+	Unmatched []string               `json:"unmatched"` // bound paths the schema does not allow
+	Invalid   []invalidValue         `json:"invalid"`   // values that could not be decoded
+}
+
+// invalidValue counts the drugs failing to decode at a path,
+// or holding a value left empty there
+type invalidValue struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+	Error string `json:"error"` // of the first value
+}
+
+// Valid returns whether the dataset matched the schema and could be decoded
+func (v *validation) Valid() bool {
+	return len(v.Unknown) == 0 && len(v.Unmatched) == 0 && len(v.Invalid) == 0
+}
+
+// validate checks the dataset at path against the schema defined in
+// xsdPath, or the built-in rules if empty, and against the Drug type
+func validate(path, xsdPath string, workers int) (*validation, error) {
+	schema := drugbank.BuiltinSchema()
+	v := &validation{Schema: "builtin"}
+	if xsdPath != "" {
+		file, err := os.Open(xsdPath)
+		if err != nil {
+			return nil, err
+		}
+		schema, err = drugbank.ReadXSD(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", xsdPath, err)
+		}
+		v.Schema = xsdPath
+	}
+
+	input, err := drugbank.OpenInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+	coverage := drugbank.NewCoverage()
+	pipeline := drugbank.NewPipeline(input, workers)
+	pipeline.Coverage = coverage
+	invalid := map[string]*invalidValue{}
+	addInvalid := func(decodeErr *drugbank.DecodeError) {
+		if value, ok := invalid[decodeErr.Path]; ok {
+			value.Count++
+			return
+		}
+		invalid[decodeErr.Path] = &invalidValue{decodeErr.Path, 1, decodeErr.Err.Error()}
+	}
+	pipeline.Reject = func(decodeErr *drugbank.DecodeError, element []byte) error {
+		v.Drugs++
+		addInvalid(decodeErr)
+		return nil
+	}
+	err = pipeline.Run(func(record *drugbank.Record) error {
+		v.Drugs++
+		for _, decodeErr := range record.Errors {
+			addInvalid(decodeErr)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	v.DrugBank = pipeline.Release()
+	v.Coverage = coverage.Score()
+	v.Unknown = schema.Unknown(coverage.Paths())
+	v.Dropped = coverage.Unbound()
+	v.Unmatched = schema.Unmatched()
+	v.Invalid = []invalidValue{}
+	for _, value := range invalid {
+		v.Invalid = append(v.Invalid, *value)
+	}
+	sort.Slice(v.Invalid, func(i, j int) bool { return v.Invalid[i].Count > v.Invalid[j].Count })
+	return v, nil
+}
+
+// writeValidation writes v as text, or as json
+func writeValidation(w io.Writer, v *validation, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+	fmt.Fprintf(w, "%s, %d drugs, checked against %s rules\n", releaseName(v.DrugBank), v.Drugs, v.Schema)
+	fmt.Fprintf(w, "Coverage: %.1f%% of the elements and attributes are bound to Drug\n", v.Coverage*100)
+	if len(v.Unknown) > 0 {
+		fmt.Fprintln(w, "\nUnknown elements and attributes, not in the schema:")
+		for _, pc := range v.Unknown {
+			fmt.Fprintf(w, "%10d  %s\n", pc.Count, pc.Path)
+		}
+	}
+	if len(v.Unmatched) > 0 {
+		fmt.Fprintln(w, "\nFields of Drug bound to paths not in the schema:")
+		for _, path := range v.Unmatched {
+			fmt.Fprintf(w, "            %s\n", path)
+		}
+	}
+	if len(v.Invalid) > 0 {
+		fmt.Fprintln(w, "\nInvalid values:")
+		for _, value := range v.Invalid {
+			fmt.Fprintf(w, "%10d  %s: %s\n", value.Count, value.Path, value.Error)
+		}
+	}
+	writeUnbound(w, v.Dropped, 0)
+	return nil
+}
+
+// unboundReported is the number of dropped paths listed after parsing
+const unboundReported = 20
+
+// writeCoverage reports the share of the xml parsed and the paths
+// dropped, so that changes to the schema of new releases stand out
+func writeCoverage(w io.Writer, coverage *drugbank.Coverage) {
+	fmt.Fprintf(w, "Coverage: %.1f%% of the elements and attributes are bound to Drug\n", coverage.Score()*100)
+	writeUnbound(w, coverage.Unbound(), unboundReported)
+}
+
+// writeUnbound writes the paths dropped by the parser, most frequent
+// first, at most limit of them unless limit is 0
+func writeUnbound(w io.Writer, unbound []drugbank.UnboundPath, limit int) {
+	if len(unbound) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nDropped elements and attributes, not bound to Drug:")
+	fmt.Fprintf(w, "%10s  %10s  %s\n", "count", "nested", "path")
+	for i, path := range unbound {
+		if limit > 0 && i == limit {
+			fmt.Fprintf(w, "            ... and %d more\n", len(unbound)-limit)
+			break
+		}
+		fmt.Fprintf(w, "%10d  %10d  %s\n", path.Count, path.Nested, path.Path)
+	}
+}
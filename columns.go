@@ -0,0 +1,128 @@
+package drugbank
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Column is a column of an output table, bound to a field of the
+// table's row type and named after the field's json tag
+type Column struct {
+	Name  string
+	Index []int // field index, as used by reflect.Value.FieldByIndex
+	Type  reflect.Type
+	Kind  Kind
+}
+
+// Kind is the type of the values of a column, independent of the
+// Go type holding them. It is used to type the columns of the
+// schemas generated for databases.
+type Kind int
+
+// Kinds of column values. Dates are the fields of type Date;
+// values of other types than the ones listed are encoded as json text.
+const (
+	KindString Kind = iota
+	KindBool
+	KindInt
+	KindFloat
+	KindDate
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindBool:
+		return "bool"
+	case KindInt:
+		return "int"
+	case KindFloat:
+		return "float"
+	case KindDate:
+		return "date"
+	default:
+		return "string"
+	}
+}
+
+// kindOf returns the kind of the values of a field
+func kindOf(field reflect.StructField) Kind {
+	if field.Type == reflect.TypeOf(Date{}) {
+		return KindDate
+	}
+	switch field.Type.Kind() {
+	case reflect.Bool:
+		return KindBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return KindInt
+	case reflect.Float32, reflect.Float64:
+		return KindFloat
+	default:
+		return KindString
+	}
+}
+
+// Columns returns the columns of a row type in struct order.
+// Embedded structs are flattened and fields tagged json:"-" are skipped,
+// so the columns match the keys of the row marshalled to json.
+func Columns(row interface{}) []Column {
+	t := reflect.TypeOf(row)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return columns(t, nil)
+}
+
+func columns(t reflect.Type, index []int) []Column {
+	var cols []Column
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+			cols = append(cols, columns(field.Type, fieldIndex)...)
+			continue
+		}
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		name := tag
+		if name == "" {
+			name = field.Name
+		}
+		cols = append(cols, Column{name, fieldIndex, field.Type, kindOf(field)})
+	}
+	return cols
+}
+
+// Value returns the value of the column in row
+func (c Column) Value(row interface{}) interface{} {
+	v := reflect.ValueOf(row)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	return v.FieldByIndex(c.Index).Interface()
+}
+
+// String returns the value of the column in row formatted as text
+func (c Column) String(row interface{}) string {
+	switch value := c.Value(row).(type) {
+	case string:
+		return value
+	case Date:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(value)
+	default:
+		text, _ := json.Marshal(value)
+		return string(text)
+	}
+}
@@ -0,0 +1,287 @@
+package drugbank
+
+import (
+	"encoding/xml"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Coverage counts the element and attribute paths found in the drugs of
+// a document, and whether the xml tags of Drug bind them to a field.
+// Paths start at the root element, e.g. drugbank/drug/products/product/name,
+// and attributes are named after their element, e.g. drugbank/drug/@type.
+// It is safe for concurrent use.
+type Coverage struct {
+	mu    sync.Mutex
+	paths map[string]*PathCount
+}
+
+// PathCount is the number of occurrences of a path
+type PathCount struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+	Bound bool   `json:"bound"` // to a field of Drug
+}
+
+// UnboundPath is an element or attribute no field binds, along with
+// the number of elements and attributes it holds, which are dropped too
+type UnboundPath struct {
+	PathCount
+	Nested int `json:"nested"`
+}
+
+// NewCoverage returns an empty Coverage
+func NewCoverage() *Coverage {
+	return &Coverage{paths: map[string]*PathCount{}}
+}
+
+// binding is a tree of the elements and attributes bound by a type,
+// attributes being named @name and elements bound by ",any" *
+type binding struct {
+	children map[string]*binding
+	all      bool // the whole element is bound, e.g. by ",innerxml"
+}
+
+// drugBinding is the binding of the drug element
+var drugBinding = newBinding(reflect.TypeOf(Drug{}))
+
+var unmarshalerType = reflect.TypeOf((*xml.Unmarshaler)(nil)).Elem()
+
+func newBinding(t reflect.Type) *binding {
+	b := &binding{children: map[string]*binding{}}
+	b.bind(t)
+	return b
+}
+
+func (b *binding) child(name string) *binding {
+	child, ok := b.children[name]
+	if !ok {
+		child = &binding{children: map[string]*binding{}}
+		b.children[name] = child
+	}
+	return child
+}
+
+// bind adds the elements and attributes bound by the fields of t
+func (b *binding) bind(t reflect.Type) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	// types decoding themselves are taken to bind all they are given,
+	// but Drug only resolves its IDs once decoded as a plain struct
+	if t != reflect.TypeOf(Drug{}) && reflect.PtrTo(t).Implements(unmarshalerType) {
+		b.all = true
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("xml")
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && tag == "" {
+			b.bind(field.Type)
+			continue
+		}
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name, flags := parts[0], parts[1:]
+		if name == "" {
+			name = field.Name
+		}
+		switch {
+		case containsFlag(flags, "attr"):
+			b.child("@" + name)
+		case containsFlag(flags, "innerxml"):
+			b.all = true
+		case containsFlag(flags, "chardata"), containsFlag(flags, "cdata"), containsFlag(flags, "comment"):
+		case containsFlag(flags, "any"):
+			b.child("*").bind(field.Type)
+		default:
+			node := b
+			for _, element := range strings.Split(name, ">") {
+				node = node.child(element)
+			}
+			node.bind(field.Type)
+		}
+	}
+}
+
+func containsFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// element returns the binding of a child element, or nil if unbound
+func (b *binding) element(name string) *binding {
+	if b == nil {
+		return nil
+	}
+	if b.all {
+		return b
+	}
+	if child, ok := b.children[name]; ok {
+		return child
+	}
+	return b.children["*"]
+}
+
+// attribute returns whether an attribute is bound
+func (b *binding) attribute(name string) bool {
+	if b == nil {
+		return false
+	}
+	_, ok := b.children["@"+name]
+	return ok || b.all
+}
+
+// paths lists the paths bound under path, except for elements bound by ",any"
+func (b *binding) paths(path string, paths []string) []string {
+	paths = append(paths, path)
+	for name, child := range b.children {
+		if name != "*" {
+			paths = child.paths(path+"/"+name, paths)
+		}
+	}
+	return paths
+}
+
+// Add counts the paths of a drug element, given as the tokens
+// of the element including its start and end elements
+func (c *Coverage) Add(tokens []xml.Token) {
+	counts := map[string]*PathCount{}
+	count := func(path string, bound bool) {
+		if pc, ok := counts[path]; ok {
+			pc.Count++
+			return
+		}
+		counts[path] = &PathCount{path, 1, bound}
+	}
+
+	path := []string{"drugbank"}
+	var bindings []*binding
+	for _, token := range tokens {
+		switch t := token.(type) {
+		case xml.StartElement:
+			var b *binding
+			if len(bindings) == 0 {
+				b = drugBinding
+			} else {
+				b = bindings[len(bindings)-1].element(t.Name.Local)
+			}
+			path = append(path, t.Name.Local)
+			bindings = append(bindings, b)
+			element := strings.Join(path, "/")
+			count(element, b != nil)
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" || attr.Name.Space == "http://www.w3.org/2001/XMLSchema-instance" {
+					continue
+				}
+				count(element+"/@"+attr.Name.Local, b.attribute(attr.Name.Local))
+			}
+		case xml.EndElement:
+			path = path[:len(path)-1]
+			bindings = bindings[:len(bindings)-1]
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path, pc := range counts {
+		if total, ok := c.paths[path]; ok {
+			total.Count += pc.Count
+			continue
+		}
+		c.paths[path] = pc
+	}
+}
+
+// Paths returns the paths found so far, sorted
+func (c *Coverage) Paths() []PathCount {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	paths := make([]PathCount, 0, len(c.paths))
+	for _, pc := range c.paths {
+		paths = append(paths, *pc)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i].Path < paths[j].Path })
+	return paths
+}
+
+// Score returns the share of the elements and attributes found
+// so far that are bound to a field, or 1 if none were found
+func (c *Coverage) Score() float64 {
+	var bound, total int
+	for _, pc := range c.Paths() {
+		total += pc.Count
+		if pc.Bound {
+			bound += pc.Count
+		}
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(bound) / float64(total)
+}
+
+// Unbound returns the unbound paths found so far, most frequent first.
+// Paths held by an unbound element are only counted as nested in it.
+func (c *Coverage) Unbound() []UnboundPath {
+	paths := c.Paths()
+	top := map[string]*UnboundPath{}
+	for _, pc := range paths {
+		if !pc.Bound {
+			top[pc.Path] = &UnboundPath{PathCount: pc}
+		}
+	}
+	var roots []*UnboundPath
+	for _, pc := range paths {
+		if pc.Bound {
+			continue
+		}
+		// counted in the outermost unbound element holding the path, if any
+		var root *UnboundPath
+		for i := strings.LastIndex(pc.Path, "/"); i > 0; i = strings.LastIndex(pc.Path[:i], "/") {
+			if ancestor, ok := top[pc.Path[:i]]; ok {
+				root = ancestor
+			}
+		}
+		if root != nil {
+			root.Nested += pc.Count
+			continue
+		}
+		roots = append(roots, top[pc.Path])
+	}
+	unbound := make([]UnboundPath, len(roots))
+	for i, root := range roots {
+		unbound[i] = *root
+	}
+	sort.SliceStable(unbound, func(i, j int) bool { return unbound[i].Count > unbound[j].Count })
+	return unbound
+}
+
+// Unmatched returns the paths bound by Drug that were not found so far,
+// sorted. Elements bound by a misspelled tag are never found.
+func (c *Coverage) Unmatched() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var unmatched []string
+	for _, path := range drugBinding.paths("drugbank/drug", nil) {
+		if _, ok := c.paths[path]; !ok {
+			unmatched = append(unmatched, path)
+		}
+	}
+	sort.Strings(unmatched)
+	return unmatched
+}
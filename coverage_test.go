@@ -0,0 +1,107 @@
+package drugbank
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+// drugTokens returns the tokens of a drug element
+func drugTokens(t *testing.T, element string) []xml.Token {
+	t.Helper()
+	decoder := xml.NewDecoder(strings.NewReader(element))
+	var tokens []xml.Token
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return tokens
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, xml.CopyToken(token))
+	}
+}
+
+const coveredDrug = `<drug xmlns="http://www.drugbank.ca" type="biotech" created="2005-06-13" updated="2018-12-03" flavour="mint">
+  <drugbank-id primary="true">DB00001</drugbank-id>
+  <drugbank-id>BTD00024</drugbank-id>
+  <name>Lepirudin</name>
+  <average-mass>6963.425</average-mass>
+  <trivia><fact>first</fact><fact>second</fact></trivia>
+</drug>`
+
+func TestCoverage(t *testing.T) {
+	c := NewCoverage()
+	if c.Score() != 1 {
+		t.Errorf("score %v with no paths, want 1", c.Score())
+	}
+	tokens := drugTokens(t, coveredDrug)
+	c.Add(tokens)
+	c.Add(tokens)
+
+	want := map[string]PathCount{
+		"drugbank/drug":                      {Count: 2, Bound: true},
+		"drugbank/drug/@type":                {Count: 2, Bound: true},
+		"drugbank/drug/@flavour":             {Count: 2},
+		"drugbank/drug/drugbank-id":          {Count: 4, Bound: true},
+		"drugbank/drug/drugbank-id/@primary": {Count: 2, Bound: true},
+		"drugbank/drug/name":                 {Count: 2, Bound: true},
+		"drugbank/drug/average-mass":         {Count: 2},
+		"drugbank/drug/trivia":               {Count: 2},
+		"drugbank/drug/trivia/fact":          {Count: 4},
+	}
+	found := map[string]bool{}
+	for _, pc := range c.Paths() {
+		found[pc.Path] = true
+		if strings.Contains(pc.Path, "xmlns") {
+			t.Errorf("namespace declaration counted as %s", pc.Path)
+		}
+		w, ok := want[pc.Path]
+		if !ok {
+			continue
+		}
+		if pc.Count != w.Count || pc.Bound != w.Bound {
+			t.Errorf("%s: %d bound %v, want %d bound %v", pc.Path, pc.Count, pc.Bound, w.Count, w.Bound)
+		}
+	}
+	for path := range want {
+		if !found[path] {
+			t.Errorf("%s not counted", path)
+		}
+	}
+
+	// 26 elements and attributes counted, 16 of them bound
+	if score := c.Score(); score != 16.0/26 {
+		t.Errorf("score %v, want %v", score, 16.0/26)
+	}
+
+	// the facts are only counted as nested in the trivia holding them
+	unbound := c.Unbound()
+	wantUnbound := []UnboundPath{
+		{PathCount{"drugbank/drug/@flavour", 2, false}, 0},
+		{PathCount{"drugbank/drug/average-mass", 2, false}, 0},
+		{PathCount{"drugbank/drug/trivia", 2, false}, 4},
+	}
+	if len(unbound) != len(wantUnbound) {
+		t.Fatalf("unbound %+v, want %+v", unbound, wantUnbound)
+	}
+	for i := range wantUnbound {
+		if unbound[i] != wantUnbound[i] {
+			t.Errorf("unbound %d is %+v, want %+v", i, unbound[i], wantUnbound[i])
+		}
+	}
+
+	unmatched := strings.Join(c.Unmatched(), "\n") + "\n"
+	for _, path := range []string{"drugbank/drug/description", "drugbank/drug/products/product/name"} {
+		if !strings.Contains(unmatched, path+"\n") {
+			t.Errorf("%s not unmatched", path)
+		}
+	}
+	for _, path := range []string{"drugbank/drug/name", "drugbank/drug/@type"} {
+		if strings.Contains(unmatched, path+"\n") {
+			t.Errorf("%s found but unmatched", path)
+		}
+	}
+}
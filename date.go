@@ -0,0 +1,105 @@
+package drugbank
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DateLayout is the ISO-8601 format of the dates of the dataset
+const DateLayout = "2006-01-02"
+
+// Date is a calendar date of the dataset, such as the last update of a
+// drug record or the expiry of a patent. It is decoded from DrugBank's
+// YYYY-MM-DD dates and written in the same format. The zero Date is a
+// missing date, written as an empty value.
+//
+// An invalid date in an attribute fails the decoding, while one in an
+// element, such as the approval of a patent, is left zero and kept
+// as the Err of the Date, for the drug holding it to still be read.
+type Date struct {
+	time.Time
+	err error // of the invalid text the date was decoded from
+}
+
+// Err returns the error of the invalid date the Date was decoded from,
+// or nil if it was valid or missing
+func (d Date) Err() error {
+	return d.err
+}
+
+// ParseDate parses a YYYY-MM-DD date. An empty text is a missing date.
+func ParseDate(text string) (Date, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Date{}, nil
+	}
+	t, err := time.Parse(DateLayout, text)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", text)
+	}
+	return Date{Time: t}, nil
+}
+
+// String returns the date in the YYYY-MM-DD format,
+// or an empty string if it is missing
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(DateLayout)
+}
+
+// UnmarshalXML decodes the date held by an element. An invalid date
+// is left zero, with its error returned by Err.
+func (d *Date) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	var text string
+	if err := decoder.DecodeElement(&text, &start); err != nil {
+		return err
+	}
+	date, err := ParseDate(text)
+	if err != nil {
+		*d = Date{err: err}
+		return nil
+	}
+	*d = date
+	return nil
+}
+
+// UnmarshalXMLAttr decodes the date held by an attribute
+func (d *Date) UnmarshalXMLAttr(attr xml.Attr) error {
+	date, err := ParseDate(attr.Value)
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
+
+// MarshalJSON writes the date as a YYYY-MM-DD string, or null if missing
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a date written by MarshalJSON
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var text *string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	if text == nil {
+		*d = Date{}
+		return nil
+	}
+	date, err := ParseDate(*text)
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
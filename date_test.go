@@ -0,0 +1,147 @@
+package drugbank
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		text  string
+		want  string
+		fails bool
+	}{
+		{"2018-12-03", "2018-12-03", false},
+		{" 2018-12-03\n", "2018-12-03", false},
+		{"", "", false},
+		{"  ", "", false},
+		{"03/12/2018", "", true},
+		{"2018-13-01", "", true},
+		{"2018-02-30", "", true},
+		{"2018-12-03T10:00:00Z", "", true},
+	}
+	for _, test := range tests {
+		date, err := ParseDate(test.text)
+		if test.fails != (err != nil) {
+			t.Errorf("%q: error %v", test.text, err)
+		}
+		if date.String() != test.want {
+			t.Errorf("%q parsed as %q, want %q", test.text, date, test.want)
+		}
+		if test.want == "" && !date.IsZero() {
+			t.Errorf("%q parsed as %v, want a zero date", test.text, date.Time)
+		}
+	}
+}
+
+func TestDateXML(t *testing.T) {
+	var patent Patent
+	err := xml.Unmarshal([]byte(`<patents><patent><number>1</number><approved>1993-01-19</approved><expires>19/01/2010</expires></patent></patents>`), &patent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patent.Approved.String() != "1993-01-19" || patent.Approved.Err() != nil {
+		t.Errorf("approved on %s (%v), want 1993-01-19", patent.Approved, patent.Approved.Err())
+	}
+	// an invalid element is left zero, with its error
+	if !patent.Expires.IsZero() || patent.Expires.Err() == nil || !strings.Contains(patent.Expires.Err().Error(), "19/01/2010") {
+		t.Errorf("expires on %v (%v), want an invalid date", patent.Expires.Time, patent.Expires.Err())
+	}
+
+	// an invalid attribute fails
+	var drug struct {
+		Updated Date `xml:"updated,attr"`
+	}
+	if err := xml.Unmarshal([]byte(`<drug updated="2019-02-08"/>`), &drug); err != nil || drug.Updated.String() != "2019-02-08" {
+		t.Errorf("updated on %s, error %v", drug.Updated, err)
+	}
+	if err := xml.Unmarshal([]byte(`<drug updated="yesterday"/>`), &drug); err == nil {
+		t.Error("invalid attribute decoded")
+	}
+}
+
+func TestDateJSON(t *testing.T) {
+	dates := []Date{{}, {Time: time.Date(2018, 12, 3, 0, 0, 0, 0, time.UTC)}}
+	for _, date := range dates {
+		data, err := json.Marshal(struct {
+			Date Date `json:"date"`
+		}{date})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"date":null}`
+		if !date.IsZero() {
+			want = `{"date":"2018-12-03"}`
+		}
+		if string(data) != want {
+			t.Errorf("%v written as %s, want %s", date.Time, data, want)
+		}
+		var read struct {
+			Date Date `json:"date"`
+		}
+		if err := json.Unmarshal(data, &read); err != nil {
+			t.Fatal(err)
+		}
+		if !read.Date.Equal(date.Time) {
+			t.Errorf("%s read back as %v, want %v", data, read.Date.Time, date.Time)
+		}
+	}
+	var read Date
+	if err := json.Unmarshal([]byte(`"12/03/2018"`), &read); err == nil {
+		t.Error("invalid date read")
+	}
+}
+
+// TestInvalidDates checks that invalid patent and product dates are
+// reported without losing the drug holding them
+func TestInvalidDates(t *testing.T) {
+	document := strings.NewReplacer(
+		"<approved>1993-01-19</approved>", "<approved>19/01/1993</approved>",
+		"<started-marketing-on>2000-01-31</started-marketing-on>", "<started-marketing-on>2000-31-01</started-marketing-on>",
+	).Replace(string(readFixture(t, 1)))
+
+	pipeline := NewPipeline(strings.NewReader(document), 2)
+	pipeline.Reject = func(err *DecodeError, element []byte) error {
+		t.Errorf("rejected: %v", err)
+		return nil
+	}
+	var errs []*DecodeError
+	var patents bytes.Buffer
+	drugs := 0
+	err := pipeline.Run(func(record *Record) error {
+		drugs++
+		errs = append(errs, record.Errors...)
+		for _, row := range record.Rows {
+			if row.Table == "patents" {
+				data, _ := json.Marshal(row.Value)
+				patents.Write(data)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drugs != 3 {
+		t.Errorf("%d drugs read, want 3", drugs)
+	}
+	want := []string{
+		"drugbank/drug/patents/patent/approved",
+		"drugbank/drug/products/product/started-marketing-on",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors %v, want %v", errs, want)
+	}
+	for i, err := range errs {
+		if err.Path != want[i] || err.ID != "DB00001" || err.Index != 0 || !err.Recoverable {
+			t.Errorf("error %+v, want a recoverable error of DB00001 at %s", err, want[i])
+		}
+	}
+	if !strings.Contains(patents.String(), `"number":"5180668","country":"United States","approved":null,"expiration":"2010-01-19"`) {
+		t.Errorf("patents %s, want 5180668 kept without its approval date", patents.String())
+	}
+}
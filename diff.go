@@ -0,0 +1,300 @@
+package drugbank
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// Diff lists the changes between two releases of the dataset: the drugs
+// added and removed, by primary ID, and for the drugs found in both the
+// changes to their scalar fields and the rows they gained or lost in
+// every child table. The rows of added and removed drugs are only counted.
+type Diff struct {
+	Old       Release                 `json:"old"`
+	New       Release                 `json:"new"`
+	Added     []DrugRef               `json:"added"`
+	Removed   []DrugRef               `json:"removed"`
+	Changed   []DrugChange            `json:"changed"`
+	Unchanged int                     `json:"unchanged"`
+	Tables    map[string]*TableChange `json:"tables"` // totals, including added and removed drugs
+}
+
+// DrugRef identifies a drug
+type DrugRef struct {
+	ID   string `json:"drugbank-id"`
+	Name string `json:"name"`
+}
+
+// DrugChange lists the changes to a drug found in both releases
+type DrugChange struct {
+	DrugRef
+	Fields []FieldChange          `json:"fields,omitempty"`
+	Tables map[string]*RowChanges `json:"tables,omitempty"`
+}
+
+// FieldChange is a scalar field of a drug whose value changed,
+// named after the column of the drugs table
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// TableChange counts the rows added to and removed from a table
+type TableChange struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// RowChanges lists the rows a drug gained and lost in a table,
+// as written by the json format
+type RowChanges struct {
+	TableChange
+	AddedRows   []json.RawMessage `json:"added-rows"`
+	RemovedRows []json.RawMessage `json:"removed-rows"`
+}
+
+// drugSummary is what a diff keeps of a drug of the old release:
+// its scalar fields and the json of each of its rows
+type drugSummary struct {
+	Name   string              `json:"name"`
+	Fields []string            `json:"fields"`
+	Rows   map[string][]string `json:"rows"` // sorted json values, by table
+}
+
+// summaryFile holds the summaries of the drugs of a release, written
+// as json lines to a temporary file and read back by drug ID
+type summaryFile struct {
+	file   *os.File
+	buffer *bufio.Writer
+	size   int64
+	index  map[string][2]int64 // offset and length of the summaries, by ID
+	order  []string            // IDs, in document order
+}
+
+func newSummaryFile() (*summaryFile, error) {
+	file, err := ioutil.TempFile("", "drugbank-diff")
+	if err != nil {
+		return nil, err
+	}
+	return &summaryFile{
+		file:   file,
+		buffer: bufio.NewWriterSize(file, 64*1024),
+		index:  map[string][2]int64{},
+	}, nil
+}
+
+// Add writes the summary of a drug, replacing any previous one with its ID
+func (f *summaryFile) Add(id string, summary *drugSummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	if _, err := f.buffer.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if _, ok := f.index[id]; !ok {
+		f.order = append(f.order, id)
+	}
+	f.index[id] = [2]int64{f.size, int64(len(data))}
+	f.size += int64(len(data)) + 1
+	return nil
+}
+
+// Get reads back the summary of a drug, or nil if there is none
+func (f *summaryFile) Get(id string) (*drugSummary, error) {
+	location, ok := f.index[id]
+	if !ok {
+		return nil, nil
+	}
+	if err := f.buffer.Flush(); err != nil {
+		return nil, err
+	}
+	data := make([]byte, location[1])
+	if _, err := f.file.ReadAt(data, location[0]); err != nil {
+		return nil, err
+	}
+	summary := &drugSummary{}
+	return summary, json.Unmarshal(data, summary)
+}
+
+// Remove forgets the summary of a drug
+func (f *summaryFile) Remove(id string) {
+	delete(f.index, id)
+}
+
+// Close closes and removes the file
+func (f *summaryFile) Close() error {
+	f.file.Close()
+	return os.Remove(f.file.Name())
+}
+
+// drugColumns are the columns of the drugs table
+var drugColumns = Columns(&Drug{})
+
+// Compare streams the documents of two releases and returns their diff.
+// A summary of every drug of the old release, its fields and the json
+// of its rows, is written to a temporary file and read back by ID as the
+// new release is read, so that only the IDs are kept in memory. Rows are
+// compared per drug, before the rows of shared entities (e.g. resources)
+// are deduplicated across drugs, and changes are listed in the order of
+// the new release, then of the old one for removed drugs.
+func Compare(old, new io.Reader, workers int) (*Diff, error) {
+	diff := &Diff{
+		Added:   []DrugRef{},
+		Removed: []DrugRef{},
+		Changed: []DrugChange{},
+		Tables:  map[string]*TableChange{},
+	}
+
+	summaries, err := newSummaryFile()
+	if err != nil {
+		return nil, err
+	}
+	defer summaries.Close()
+	pipeline := NewPipeline(old, workers)
+	err = pipeline.Run(func(record *Record) error {
+		return summaries.Add(record.Drug.ID, summarize(record.Drug))
+	})
+	if err != nil {
+		return nil, err
+	}
+	diff.Old = pipeline.Release()
+
+	pipeline = NewPipeline(new, workers)
+	err = pipeline.Run(func(record *Record) error {
+		d := summarize(record.Drug)
+		ref := DrugRef{record.Drug.ID, d.Name}
+		previous, err := summaries.Get(ref.ID)
+		if err != nil {
+			return err
+		}
+		if previous == nil {
+			diff.Added = append(diff.Added, ref)
+			for table, rows := range d.Rows {
+				diff.table(table).Added += len(rows)
+			}
+			return nil
+		}
+		summaries.Remove(ref.ID)
+		change := DrugChange{DrugRef: ref}
+		for i, column := range drugColumns {
+			if previous.Fields[i] != d.Fields[i] {
+				change.Fields = append(change.Fields, FieldChange{column.Name, previous.Fields[i], d.Fields[i]})
+			}
+		}
+		for _, table := range tableNames(previous.Rows, d.Rows) {
+			rows := compareRows(previous.Rows[table], d.Rows[table])
+			if rows.Added == 0 && rows.Removed == 0 {
+				continue
+			}
+			if change.Tables == nil {
+				change.Tables = map[string]*RowChanges{}
+			}
+			change.Tables[table] = rows
+			diff.table(table).Added += rows.Added
+			diff.table(table).Removed += rows.Removed
+		}
+		if change.Fields == nil && change.Tables == nil {
+			diff.Unchanged++
+			return nil
+		}
+		diff.Changed = append(diff.Changed, change)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	diff.New = pipeline.Release()
+
+	for _, id := range summaries.order {
+		d, err := summaries.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if d == nil {
+			continue
+		}
+		diff.Removed = append(diff.Removed, DrugRef{id, d.Name})
+		for table, rows := range d.Rows {
+			diff.table(table).Removed += len(rows)
+		}
+	}
+	return diff, nil
+}
+
+// table returns the totals of a table, creating them if needed
+func (diff *Diff) table(name string) *TableChange {
+	change, ok := diff.Tables[name]
+	if !ok {
+		change = &TableChange{}
+		diff.Tables[name] = change
+	}
+	return change
+}
+
+// summarize returns the summary of a drug used to compare it.
+// The drugs table is left out of the rows, its columns are the fields.
+func summarize(d *Drug) *drugSummary {
+	summary := &drugSummary{
+		Name:   d.Name,
+		Fields: make([]string, len(drugColumns)),
+		Rows:   map[string][]string{},
+	}
+	for i, column := range drugColumns {
+		summary.Fields[i] = column.String(d)
+	}
+	for _, row := range Rows(d) {
+		if row.Table == "drugs" {
+			continue
+		}
+		value, _ := json.Marshal(row.Value)
+		summary.Rows[row.Table] = append(summary.Rows[row.Table], string(value))
+	}
+	for _, rows := range summary.Rows {
+		sort.Strings(rows)
+	}
+	return summary
+}
+
+// tableNames returns the tables of either set of rows, sorted
+func tableNames(old, new map[string][]string) []string {
+	var names []string
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// compareRows lists the rows only found in new (added) and only found
+// in old (removed). Both are sorted and may hold duplicates, which are
+// listed as many times as they appear.
+func compareRows(old, new []string) *RowChanges {
+	changes := &RowChanges{AddedRows: []json.RawMessage{}, RemovedRows: []json.RawMessage{}}
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		switch {
+		case i < len(old) && j < len(new) && old[i] == new[j]:
+			i++
+			j++
+		case j == len(new) || (i < len(old) && old[i] < new[j]):
+			changes.RemovedRows = append(changes.RemovedRows, json.RawMessage(old[i]))
+			i++
+		default:
+			changes.AddedRows = append(changes.AddedRows, json.RawMessage(new[j]))
+			j++
+		}
+	}
+	changes.Added, changes.Removed = len(changes.AddedRows), len(changes.RemovedRows)
+	return changes
+}
@@ -0,0 +1,157 @@
+package drugbank
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	old := string(readFixture(t, 1))
+	// the new release drops the second drug, changes the third and adds one
+	start := strings.Index(old, `<drug type="small molecule" created="2005-06-13" updated="2019-02-08">`)
+	end := strings.Index(old, `<drug type="small molecule" created="2005-06-13" updated="2017-01-01">`)
+	new := old[:start] + old[end:]
+	new = strings.NewReplacer(
+		"(DNase I) enzyme.", "(DNase I).",
+		"<group>approved</group>\n  </groups>\n  <general-references/>", "<group>investigational</group>\n  </groups>\n  <general-references/>",
+		"47.43", "50.12",
+	).Replace(new)
+	added := strings.Replace(brokenDrug, "not a number", "12.5", 1)
+	new = strings.Replace(new, "</drugbank>", added+"</drugbank>", 1)
+
+	diff, err := Compare(strings.NewReader(old), strings.NewReader(new), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0] != (DrugRef{"DB99999", "Broken"}) {
+		t.Errorf("added %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0] != (DrugRef{"DB00002", "Cetuximab"}) {
+		t.Errorf("removed %v", diff.Removed)
+	}
+	if diff.Unchanged != 1 {
+		t.Errorf("%d drugs unchanged, want 1", diff.Unchanged)
+	}
+	if len(diff.Changed) != 1 {
+		t.Fatalf("changed %v", diff.Changed)
+	}
+
+	change := diff.Changed[0]
+	if change.ID != "DB00003" {
+		t.Errorf("changed %s, want DB00003", change.ID)
+	}
+	if len(change.Fields) != 1 || change.Fields[0].Field != "description" ||
+		!strings.HasSuffix(change.Fields[0].Old, "(DNase I) enzyme.") || !strings.HasSuffix(change.Fields[0].New, "(DNase I).") {
+		t.Errorf("fields %+v", change.Fields)
+	}
+	if len(change.Tables) != 2 {
+		t.Errorf("tables %v, want groups and prices", change.Tables)
+	}
+	for table, want := range map[string][2]string{
+		"groups": {"approved", "investigational"},
+		"prices": {"47.43", "50.12"},
+	} {
+		rows, ok := change.Tables[table]
+		if !ok {
+			t.Errorf("no changes to %s", table)
+			continue
+		}
+		if rows.Added != 1 || rows.Removed != 1 || len(rows.AddedRows) != 1 || len(rows.RemovedRows) != 1 {
+			t.Errorf("%s: %+v, want a row added and a row removed", table, rows)
+			continue
+		}
+		if !bytes.Contains(rows.RemovedRows[0], []byte(want[0])) || !bytes.Contains(rows.RemovedRows[0], []byte("DB00003")) {
+			t.Errorf("%s: removed %s, want the row holding %s", table, rows.RemovedRows[0], want[0])
+		}
+		if !bytes.Contains(rows.AddedRows[0], []byte(want[1])) {
+			t.Errorf("%s: added %s, want the row holding %s", table, rows.AddedRows[0], want[1])
+		}
+	}
+
+	// totals include the rows of the drugs added and removed
+	if prices := diff.Tables["prices"]; prices == nil || prices.Added != 2 || prices.Removed != 1 {
+		t.Errorf("prices %+v, want the rows of DB99999 and DB00003 added", prices)
+	}
+	if groups := diff.Tables["groups"]; groups == nil || groups.Removed != 2 {
+		t.Errorf("groups %+v, want the rows of DB00002 and DB00003 removed", groups)
+	}
+}
+
+func TestCompareRows(t *testing.T) {
+	tests := []struct {
+		old, new         []string
+		added, removed   []string
+		addedN, removedN int
+	}{
+		{nil, nil, nil, nil, 0, 0},
+		{[]string{"a", "b"}, []string{"a", "b"}, nil, nil, 0, 0},
+		{[]string{"a"}, []string{"a", "b"}, []string{"b"}, nil, 1, 0},
+		{[]string{"a", "b", "c"}, []string{"b"}, nil, []string{"a", "c"}, 0, 2},
+		// duplicates are matched one for one
+		{[]string{"a", "a", "b"}, []string{"a", "b", "b"}, []string{"b"}, []string{"a"}, 1, 1},
+		{[]string{"b"}, []string{"a", "c"}, []string{"a", "c"}, []string{"b"}, 2, 1},
+	}
+	for _, test := range tests {
+		changes := compareRows(test.old, test.new)
+		if changes.Added != test.addedN || changes.Removed != test.removedN {
+			t.Errorf("%v -> %v: +%d -%d, want +%d -%d", test.old, test.new,
+				changes.Added, changes.Removed, test.addedN, test.removedN)
+		}
+		if got := join(changes.AddedRows); got != strings.Join(test.added, ",") {
+			t.Errorf("%v -> %v: added %s, want %v", test.old, test.new, got, test.added)
+		}
+		if got := join(changes.RemovedRows); got != strings.Join(test.removed, ",") {
+			t.Errorf("%v -> %v: removed %s, want %v", test.old, test.new, got, test.removed)
+		}
+	}
+}
+
+func join(rows []json.RawMessage) string {
+	var values []string
+	for _, row := range rows {
+		values = append(values, string(row))
+	}
+	return strings.Join(values, ",")
+}
+
+func TestSummaryFile(t *testing.T) {
+	summaries, err := newSummaryFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := summaries.file.Name()
+	first := &drugSummary{Name: "Lepirudin", Fields: []string{"DB00001"}, Rows: map[string][]string{"groups": {`{"group":"approved"}`}}}
+	second := &drugSummary{Name: "Cetuximab", Fields: []string{"DB00002"}, Rows: map[string][]string{}}
+	for _, add := range []struct {
+		id      string
+		summary *drugSummary
+	}{{"DB00001", first}, {"DB00002", second}, {"DB00001", second}} {
+		if err := summaries.Add(add.id, add.summary); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if join := strings.Join(summaries.order, ","); join != "DB00001,DB00002" {
+		t.Errorf("order %s, want DB00001,DB00002", join)
+	}
+	// the last summary written for an ID is read back
+	got, err := summaries.Get("DB00001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Name != "Cetuximab" {
+		t.Errorf("DB00001 read back as %+v", got)
+	}
+	summaries.Remove("DB00001")
+	if got, err := summaries.Get("DB00001"); got != nil || err != nil {
+		t.Errorf("removed summary read back as %+v, %v", got, err)
+	}
+	if err := summaries.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("%s left after Close: %v", path, err)
+	}
+}
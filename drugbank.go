@@ -1,613 +1,18 @@
-// Package main parses the drugbank dataset xml file
-package main
-
-// TODO
-//
-// <transporters/> -> complex structure containing a polypeptide.
-// 					  may it's not worth the hassle given the info
-// <transmembrane-regions/> -> it's within a Polypeptide
+// Package drugbank parses the drugbank dataset xml file
+package drugbank
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/json"
 	"encoding/xml"
-	"fmt"
-	"io"
-	"io/ioutil"
-	"log"
-	"os"
-	"path/filepath"
-	"strings"
-	"time"
-
-	"github.com/docopt/docopt-go"
-
-	"github.com/schollz/progressbar"
 )
 
-var version = "0.1"
-
-func main() {
-	usage := `Drugbank parser.
-
-	Usage:
-		drugbank parse <path> <outputdir>
-		drugbank process <path> <outputdir> <host> [--password=<password> | --user=<user>]
-		drugbank -h | --help
-		drugbank --version
-	
-	Options:
-		--password=<password>		Password for Tigergraph instance.
-		--user=<user> 			Username for Tigergraph instance.
-		-h --help     			Show this screen.
-		--version    	 		Show version.`
-
-	arguments, _ := docopt.ParseArgs(usage, os.Args[1:], version)
-	defer TimeTrack("main", time.Now())
-
-	if p, _ := arguments.Bool("parse"); p {
-		path, _ := arguments.String("<path>")
-		outputdir, _ := arguments.String("<outputdir>")
-		fmt.Printf("Parsing %s to %s\n", path, outputdir)
-		parse(path, outputdir)
-		fmt.Println("Done.")
-		os.Exit(0)
-	}
-
-	if p, _ := arguments.Bool("process"); p {
-		path, _ := arguments.String("<path>")
-		outputdir, _ := arguments.String("<outputdir>")
-		host, _ := arguments.String("<host>")
-		fmt.Printf("Parsing %s to %s...\n", path, outputdir)
-		// parse(path, outputdir)
-		fmt.Println("Done parsing")
-		fmt.Printf("Uploading data to %s...\n", host)
-		upload(outputdir)
-		os.Exit(0)
-	}
-}
-
-func upload(directory string) {
-	defer TimeTrack("upload", time.Now())
-	files, _ := filepath.Glob(filepath.Join(directory, "*.json"))
-
-	fmt.Println("Uploading nodes...")
-	for _, file := range files {
-		contents, _ := ioutil.ReadFile(file)
-		fmt.Println(string(contents))
-
-		break
-	}
-	fmt.Println("Uploading edges...")
-	// for _, file := range files {
-
-	// }
-}
-
-func parse(path, outputdir string) {
-	defer TimeTrack("parse", time.Now())
-	xmlFile, err := os.Open(path)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer xmlFile.Close()
-	decoder := xml.NewDecoder(xmlFile)
-	numberOfDrugs := getDrugsNumber(xmlFile)
-	bar := progressbar.New(numberOfDrugs)
-	// drugs := []Drug{}
-	xmlFile.Seek(0, 0)
-	count := 0
-
-	var (
-		jsonDrugs             [][]byte
-		jsonManufacturers     [][]byte
-		jsonDrugManufacturers [][]byte
-		jsonProducts          [][]byte
-		jsonDrugProducts      [][]byte
-		jsonReactions         [][]byte
-		seenReaction          map[string]bool
-		jsonAdverseReactions  [][]byte
-		jsonSNPEffects        [][]byte
-		jsonGroups            [][]byte
-		jsonBooks             [][]byte
-		jsonArticles          [][]byte
-		jsonLinks             [][]byte
-		jsonClassifications   [][]byte
-		jsonSynonyms          [][]byte
-		jsonMixtures          [][]byte
-		jsonPackagers         [][]byte
-		jsonPrices            [][]byte
-		jsonCategories        [][]byte
-		jsonOrganisms         [][]byte
-		jsonATCCodes          [][]byte
-		jsonATCLevels         [][]byte
-		jsonDosages           [][]byte
-		jsonPatents           [][]byte
-		jsonDrugInteractions  [][]byte
-		jsonFoodInteractions  [][]byte
-		jsonProperties        [][]byte
-		jsonExtID             [][]byte
-		jsonExtLinks          [][]byte
-	)
-
-	for {
-		token, _ := decoder.Token()
-		if token == nil {
-			break
-		}
-		switch startElement := token.(type) {
-		case xml.StartElement:
-			if startElement.Name.Local == "drug" {
-				var d Drug
-				decoder.DecodeElement(&d, &startElement)
-				// drugs = append(drugs, d)
-
-				// DRUG
-				jsonDrug, _ := json.Marshal(d)
-				jsonDrugs = append(jsonDrugs, jsonDrug)
-
-				// CLASSIFICATION
-				jsonClassification, _ := json.Marshal(struct {
-					ID string `json:"drugbank-id"`
-					Classification
-				}{
-					d.ID,
-					d.Classification,
-				})
-				jsonClassifications = append(jsonClassifications, jsonClassification)
-
-				// MANUFACTURERS
-				for _, manufacturer := range d.Manufacturers {
-					if manufacturer.Name == "" {
-						continue
-					}
-					jsonManufacturer, _ := json.Marshal(manufacturer)
-					drugManufacturer := struct {
-						DrugID         string `json:"drugbank-id"`
-						ManufacturerID string `json:"manufacturer-id"`
-					}{
-						d.ID,
-						manufacturer.Name,
-					}
-					jsonManufacturers = append(jsonManufacturers, jsonManufacturer)
-
-					jsonDrugManufacturer, _ := json.Marshal(drugManufacturer)
-					jsonDrugManufacturers = append(jsonDrugManufacturers, jsonDrugManufacturer)
-				}
-
-				// PRODUCTS
-				for _, product := range d.Products {
-					jsonProduct, _ := json.Marshal(product)
-					drugProduct := struct {
-						DrugID    string `json:"drugbank-id"`
-						ProductID string `json:"name"`
-					}{
-						d.ID,
-						product.Name,
-					}
-
-					jsonDrugProduct, _ := json.Marshal(drugProduct)
-
-					jsonProducts = append(jsonProducts, jsonProduct)
-					jsonDrugProducts = append(jsonDrugProducts, jsonDrugProduct)
-				}
-
-				// REACTIONS
-				for _, reaction := range d.Reactions {
-					_, ok := seenReaction[reaction.Sequence]
-					if ok {
-						continue
-					}
-					jsonReaction, _ := json.Marshal(struct {
-						Sequence  string `json:"sequence"`
-						LeftID    string `json:"left-id"`
-						LeftName  string `json:"left-name"`
-						RightID   string `json:"right-id"`
-						RightName string `json:"right-name"`
-					}{
-						reaction.Sequence,
-						reaction.Left.ID,
-						reaction.Left.Name,
-						reaction.Right.ID,
-						reaction.Right.Name,
-					})
-					jsonReactions = append(jsonReactions, jsonReaction)
-				}
-
-				// ADVERSE REACTIONS
-				for _, reaction := range d.AdverseReactions {
-					if reaction.UNIPROTID == "" {
-						continue
-					}
-					jsonAdverseReaction, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						AdverseReaction
-					}{
-						d.ID,
-						reaction,
-					})
-					jsonAdverseReactions = append(jsonAdverseReactions, jsonAdverseReaction)
-				}
-
-				// SNP EFFECTS
-				for _, effect := range d.SNPEffects {
-					if effect.UNIPROTID == "" {
-						continue
-					}
-					jsonEffect, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						SNPEffect
-					}{
-						d.ID,
-						effect,
-					})
-					jsonSNPEffects = append(jsonSNPEffects, jsonEffect)
-				}
-
-				// GROUPS
-				for _, group := range d.Groups {
-					if group.Name == "" {
-						continue
-					}
-					jsonGroup, _ := json.Marshal(struct {
-						ID   string `json:"drugbank-id"`
-						Name string `json:"name"`
-					}{
-						d.ID,
-						group.Name,
-					})
-					jsonGroups = append(jsonGroups, jsonGroup)
-				}
-
-				// REFERENCES
-				// BOOKS
-				for _, book := range d.References.Books {
-					if book.ISBN == "" {
-						continue
-					}
-					jsonBook, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						Book
-					}{
-						d.ID,
-						book,
-					})
-					jsonBooks = append(jsonBooks, jsonBook)
-				}
-
-				// LINKS
-				for _, link := range d.References.Links {
-					if link.URL == "" {
-						continue
-					}
-					jsonLink, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						Link
-					}{
-						d.ID,
-						link,
-					})
-					jsonLinks = append(jsonLinks, jsonLink)
-				}
-
-				// PAPERS
-				for _, paper := range d.References.Articles {
-					if paper.PubMedID == "" {
-						continue
-					}
-					jsonArticle, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						Article
-					}{
-						d.ID,
-						paper,
-					})
-					jsonArticles = append(jsonArticles, jsonArticle)
-				}
-
-				// SYNONYMS
-				for _, syn := range d.Synonyms {
-					if syn.Synonym == "" {
-						continue
-					}
-					jsonSynonym, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						Synonym
-					}{
-						d.ID,
-						syn,
-					})
-					jsonSynonyms = append(jsonSynonyms, jsonSynonym)
-				}
-
-				// MIXTURES
-				for _, mix := range d.Mixtures {
-					if mix.Name == "" {
-						continue
-					}
-					jsonMixture, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						Mixture
-					}{
-						d.ID,
-						mix,
-					})
-					jsonMixtures = append(jsonMixtures, jsonMixture)
-				}
-
-				// PACKAGERS
-				for _, pack := range d.Packagers {
-					if pack.Name == "" {
-						continue
-					}
-					jsonPackager, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						Packager
-					}{
-						d.ID,
-						pack,
-					})
-					jsonPackagers = append(jsonPackagers, jsonPackager)
-				}
-
-				// PRICES
-				for _, price := range d.Prices {
-					if price.Details.Amount == 0.0 {
-						continue
-					}
-					jsonPrice, _ := json.Marshal(struct {
-						DrugID      string  `json:"drugbank-id"`
-						Description string  `json:"description"`
-						Amount      float64 `json:"cost"`
-						Currency    string  `json:"currency"`
-						Unit        string  `json:"sale-unit"`
-					}{
-						d.ID,
-						price.Description,
-						price.Details.Amount,
-						price.Details.Currency,
-						price.Unit,
-					})
-					jsonPrices = append(jsonPrices, jsonPrice)
-				}
-
-				// CATEGORY
-				for _, cat := range d.Categories {
-					if cat.Category == "" {
-						continue
-					}
-					jsonCategory, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						Category
-					}{
-						d.ID,
-						cat,
-					})
-					jsonCategories = append(jsonCategories, jsonCategory)
-				}
-
-				// AFFECTED ORGANISMS
-				for _, org := range d.AffectedOrganisms {
-					if org.Description == "" {
-						continue
-					}
-					jsonOrganism, _ := json.Marshal(struct {
-						DrugID   string `json:"drugbank-id"`
-						Organism string `json:"organism"`
-					}{
-						d.ID,
-						org.Description,
-					})
-					jsonOrganisms = append(jsonOrganisms, jsonOrganism)
-				}
-
-				// ATC CODES
-				for _, code := range d.ATCCodes {
-					jsonCode, _ := json.Marshal(struct {
-						ATCCode string `json:"atc-code"`
-						DrugID  string `json:"drugbank-id"`
-					}{
-						code.Code.Code,
-						d.ID,
-					})
-
-					jsonATCCodes = append(jsonATCCodes, jsonCode)
-
-					for _, level := range code.Code.Levels {
-
-						jsonLevel, _ := json.Marshal(struct {
-							ATCCode      string `json:"atc-code"`
-							ATCLevelCode string `json:"atc-level"`
-							Description  string `json:"description"`
-						}{
-							code.Code.Code,
-							level.Code,
-							level.Description,
-						})
-
-						jsonATCLevels = append(jsonATCLevels, jsonLevel)
-					}
-				}
-
-				// DOSAGE
-				for _, dosage := range d.Dosages {
-					if dosage.Form == "" {
-						continue
-					}
-					jsonDosage, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						Dosage
-					}{
-						d.ID,
-						dosage,
-					})
-					jsonDosages = append(jsonDosages, jsonDosage)
-				}
-
-				// PATENT
-				for _, patent := range d.Patents {
-					if patent.Number == "" {
-						continue
-					}
-					jsonPatent, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						Patent
-					}{
-						d.ID,
-						patent,
-					})
-					jsonPatents = append(jsonPatents, jsonPatent)
-				}
-
-				// DRUG INTERACTION
-				for _, interaction := range d.DrugInteractions {
-					if interaction.ID == "" {
-						continue
-					}
-					jsonInteraction, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						DrugInteraction
-					}{
-						d.ID,
-						interaction,
-					})
-					jsonDrugInteractions = append(jsonDrugInteractions, jsonInteraction)
-				}
-
-				// FOOD INTERACTION
-				for _, interaction := range d.FoodInteractions {
-					if interaction == "" {
-						continue
-					}
-					jsonInteraction, _ := json.Marshal(struct {
-						DrugID      string `json:"drugbank-id"`
-						Interaction string `json:"interaction"`
-					}{
-						d.ID,
-						interaction,
-					})
-					jsonFoodInteractions = append(jsonFoodInteractions, jsonInteraction)
-				}
-
-				// PROPERTIES
-				for _, property := range d.ExperimentalProperties {
-					if property.Value == "" {
-						continue
-					}
-					jsonProperty, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						Property
-					}{
-						d.ID,
-						property,
-					})
-					jsonProperties = append(jsonProperties, jsonProperty)
-				}
-
-				// EXTERNAL LINK
-				for _, link := range d.ExternalLinks {
-					if link.URL == "" {
-						continue
-					}
-					jsonLink, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						ExternalLink
-					}{
-						d.ID,
-						link,
-					})
-					jsonExtLinks = append(jsonExtLinks, jsonLink)
-				}
-
-				// EXTERNAL IDENTIFIERS
-				for _, id := range d.ExternalIdentifiers {
-					if id.Identifier == "" {
-						continue
-					}
-					jsonID, _ := json.Marshal(struct {
-						DrugID string `json:"drugbank-id"`
-						ExternalIdentifier
-					}{
-						d.ID,
-						id,
-					})
-					jsonExtID = append(jsonExtID, jsonID)
-				}
-			}
-		}
-		count++
-		bar.Add(1)
-	}
-	fmt.Println()
-
-	err = os.MkdirAll(outputdir, 0770)
-	if err != nil {
-		log.Fatal(err)
-	}
-	ioutil.WriteFile(filepath.Join(outputdir, "drugs.json"), bytes.Join(jsonDrugs, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "classifications.json"), bytes.Join(jsonClassifications, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "manufacturers.json"), bytes.Join(jsonManufacturers, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "drugs-manufacturers-join.json"), bytes.Join(jsonDrugManufacturers, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "products.json"), bytes.Join(jsonProducts, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "drugs-products-join.json"), bytes.Join(jsonDrugProducts, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "reactions.json"), bytes.Join(jsonReactions, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "adverse-reactions.json"), bytes.Join(jsonReactions, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "snp-effects.json"), bytes.Join(jsonSNPEffects, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "groups.json"), bytes.Join(jsonGroups, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "articles.json"), bytes.Join(jsonArticles, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "books.json"), bytes.Join(jsonBooks, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "links.json"), bytes.Join(jsonLinks, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "synonyms.json"), bytes.Join(jsonSynonyms, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "mixtures.json"), bytes.Join(jsonMixtures, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "packagers.json"), bytes.Join(jsonPackagers, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "prices.json"), bytes.Join(jsonPrices, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "categories.json"), bytes.Join(jsonCategories, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "organisms.json"), bytes.Join(jsonOrganisms, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "atc_codes.json"), bytes.Join(jsonATCCodes, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "atc_levels.json"), bytes.Join(jsonATCLevels, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "dosages.json"), bytes.Join(jsonDosages, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "patents.json"), bytes.Join(jsonPatents, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "drug_interactions.json"), bytes.Join(jsonDrugInteractions, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "food_interactions.json"), bytes.Join(jsonFoodInteractions, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "experimental_properties.json"), bytes.Join(jsonProperties, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "external_links.json"), bytes.Join(jsonExtLinks, []byte("\n")), 0644)
-	ioutil.WriteFile(filepath.Join(outputdir, "external_identifiers.json"), bytes.Join(jsonExtID, []byte("\n")), 0644)
-}
-
-// getDrugsNumber counts the number of opening drug tags
-// in the xml file. Th tags that contain attributes are top-level
-// tags for drugs
-func getDrugsNumber(file io.Reader) int {
-	defer TimeTrack("getDrugsNumber", time.Now())
-	counter := 0
-	pattern := "<drug type="
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		if strings.Contains(scanner.Text(), pattern) {
-			counter++
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
-	}
-	return counter
-}
-
-// TimeTrack tracks the execution time of a function
-func TimeTrack(name string, start time.Time) {
-	elapsed := time.Since(start)
-	fmt.Printf("%s took %.2f seconds\n", name, elapsed.Seconds())
-}
-
 // Drug represents a drug and all its related information
 // More detailed info available at https://www.drugbank.ca/documentation#drug-cards
 type Drug struct {
-	ID                     string               `xml:"drugbank-id" json:"drugbank-id"`
-	DrugRecordCreatedOn    string               `xml:"created,attr" json:"record-creation"`
-	DrugRecordUpdatedOn    string               `xml:"updated,attr" json:"record-update"`
+	ID                     string               `xml:"-" json:"drugbank-id"` // primary DrugBank ID
+	SecondaryIDs           []string             `xml:"-" json:"-"`           // legacy IDs (APRD, BTD, BIOD, ...)
+	IDs                    []DrugbankID         `xml:"drugbank-id" json:"-"`
+	DrugRecordCreatedOn    Date                 `xml:"created,attr" json:"record-creation"`
+	DrugRecordUpdatedOn    Date                 `xml:"updated,attr" json:"record-update"`
 	DrugType               string               `xml:"type,attr" json:"drug-type"`
 	Name                   string               `xml:"name" json:"name"`
 	Description            string               `xml:"description" json:"description"`
@@ -617,7 +22,7 @@ type Drug struct {
 	Groups                 []Group              `xml:"groups" json:"-"`
 	References             Reference            `xml:"general-references" json:"-"`
 	Indication             string               `xml:"indication" json:"indication"`
-	Pharmacodynamics       string               `xml:"pharmacodynamics" json:"pharmacodynamycs"`
+	Pharmacodynamics       string               `xml:"pharmacodynamics" json:"pharmacodynamics"`
 	MechanismOfAction      string               `xml:"mechanism-of-action" json:"mechanism-of-action"`
 	Toxicity               string               `xml:"toxicity" json:"toxicity"`
 	Metabolism             string               `xml:"metabolism" json:"metabolism"`
@@ -643,6 +48,7 @@ type Drug struct {
 	DrugInteractions       []DrugInteraction    `xml:"drug-interactions" json:"-"`
 	Sequences              []Sequence           `xml:"sequences>sequence" json:"-"`
 	ExperimentalProperties []Property           `xml:"experimental-properties>property" json:"-"`
+	CalculatedProperties   []Property           `xml:"calculated-properties>property" json:"-"`
 	ExternalIdentifiers    []ExternalIdentifier `xml:"external-identifiers>external-identifier" json:"-"`
 	ExternalLinks          []ExternalLink       `xml:"external-links>external-link" json:"-"`
 	Targets                []Target             `xml:"targets>target" json:"-"`
@@ -650,14 +56,78 @@ type Drug struct {
 	SynthesysReference     string               `xml:"synthesis-reference" json:"synthesis-reference"`
 	ProteinBinding         string               `xml:"protein-binding" json:"protein-binding"`
 	Salts                  []Salt               `xml:"salts>salt" json:"-"`
-	InternationalBrands    []Brand              `xml:"internation-brands>international-brand" json:"-"`
-	AHFSCodes              []string             `xml:"ahfs-code>ahfs-code" json:"-"`
+	InternationalBrands    []Brand              `xml:"international-brands>international-brand" json:"-"`
+	AHFSCodes              []string             `xml:"ahfs-codes>ahfs-code" json:"-"`
 	PDBEntries             []string             `xml:"pdb-entries>pdb-entry" json:"-"`
 	FoodInteractions       []string             `xml:"food-interactions>food-interaction" json:"-"`
 	Reactions              []Reaction           `xml:"reactions>reaction" json:"-"`
 	SNPEffects             []SNPEffect          `xml:"snp-effects>effect" json:"-"`
-	AdverseReactions       []AdverseReaction    `xml:"snp-adverse-drug-reaction>reaction" json:"-"`
+	AdverseReactions       []AdverseReaction    `xml:"snp-adverse-drug-reactions>reaction" json:"-"`
+	Enzymes                []Enzyme             `xml:"enzymes>enzyme" json:"-"`
 	Carriers               []Carrier            `xml:"carriers>carrier" json:"-"`
+	Transporters           []Transporter        `xml:"transporters>transporter" json:"-"`
+}
+
+// UnmarshalXML decodes a drug element and resolves its primary
+// and secondary DrugBank IDs from the drugbank-id elements.
+func (d *Drug) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	type drug Drug // avoids recursing into UnmarshalXML
+	if err := decoder.DecodeElement((*drug)(d), &start); err != nil {
+		return err
+	}
+	d.resolveIDs()
+	return nil
+}
+
+// Calculated returns the value of the first calculated property of the
+// given kind (e.g. "SMILES", "InChIKey"), or an empty string if the drug has none
+func (d *Drug) Calculated(kind string) string {
+	for _, property := range d.CalculatedProperties {
+		if property.Kind == kind && property.Value != "" {
+			return property.Value
+		}
+	}
+	return ""
+}
+
+// invalidDates returns the errors of the dates of d left zero because
+// they were invalid, located by the path of their element
+func (d *Drug) invalidDates() []*DecodeError {
+	var invalid []*DecodeError
+	check := func(path string, date Date) {
+		if err := date.Err(); err != nil {
+			invalid = append(invalid, &DecodeError{Path: path, ID: d.ID, Err: err, Recoverable: true})
+		}
+	}
+	for _, patent := range d.Patents {
+		check("drugbank/drug/patents/patent/approved", patent.Approved)
+		check("drugbank/drug/patents/patent/expires", patent.Expires)
+	}
+	for _, product := range d.Products {
+		check("drugbank/drug/products/product/started-marketing-on", product.StartedMarketing)
+		check("drugbank/drug/products/product/ended-marketing-on", product.EndedMarketing)
+	}
+	return invalid
+}
+
+// resolveIDs sets ID to the drugbank-id marked as primary
+// (falling back to the first one) and SecondaryIDs to the others.
+func (d *Drug) resolveIDs() {
+	d.ID = ""
+	d.SecondaryIDs = nil
+	for _, id := range d.IDs {
+		if id.Primary && d.ID == "" {
+			d.ID = id.Value
+		}
+	}
+	if d.ID == "" && len(d.IDs) > 0 {
+		d.ID = d.IDs[0].Value
+	}
+	for _, id := range d.IDs {
+		if id.Value != d.ID {
+			d.SecondaryIDs = append(d.SecondaryIDs, id.Value)
+		}
+	}
 }
 
 // AdverseReaction represents a possible adverse reaction a drug may cause
@@ -688,6 +158,20 @@ type ATCCode struct {
 	} `xml:"atc-code"`
 }
 
+// BioEntity holds the details shared by targets, enzymes, carriers
+// and transporters: the entity a drug acts on, how it acts on it
+// and the polypeptides the entity is made of.
+type BioEntity struct {
+	Position     string        `xml:"position,attr"`
+	ID           string        `xml:"id"`
+	Name         string        `xml:"name"`
+	Organism     string        `xml:"organism"`
+	Actions      []string      `xml:"actions>action"`
+	References   []Reference   `xml:"references"`
+	KnownAction  string        `xml:"known-action"`
+	Polypeptides []Polypeptide `xml:"polypeptide"`
+}
+
 // Book represents a textbook regarding a drug
 type Book struct {
 	ISBN     string `xml:"textbook>isbn" json:"isbn"`
@@ -709,14 +193,7 @@ type Brand struct {
 // and in this case a transporter can also be the target
 // (for example: Procaine targeting the Sodium-dependent dopamine transporter).
 type Carrier struct {
-	Position    string      `xml:"position,attr"`
-	ID          string      `xml:"id"`
-	Name        string      `xml:"name"`
-	Organism    string      `xml:"organism"`
-	Actions     []string    `xml:"actions>action"`
-	References  []Reference `xml:"references"`
-	KnownAction string      `xml:"known-action"`
-	Polypeptide Polypeptide `xml:"polypeptide"`
+	BioEntity
 }
 
 // Category represents a category of sub-division
@@ -743,6 +220,13 @@ type Dosage struct {
 	Strength string `xml:"dosage>strength" json:"strength"` // TODO
 }
 
+// DrugbankID is a DrugBank identifier. Each drug has exactly one
+// primary ID (DBxxxxx) and may keep legacy ones from older releases.
+type DrugbankID struct {
+	Value   string `xml:",chardata"`
+	Primary bool   `xml:"primary,attr"`
+}
+
 // DrugInteraction represents a possible interaction between to drugs
 type DrugInteraction struct {
 	ID          string `xml:"drug-interaction>drugbank-id" json:"reagent-id"`
@@ -750,9 +234,13 @@ type DrugInteraction struct {
 	Description string `xml:"drug-interaction>description" json:"description"`
 }
 
-// Enzyme contains the enzyme ID on UNIPROT
+// Enzyme represents a protein which catalyzes chemical reactions
+// involving the drug, e.g. to metabolize it. Drugs may inhibit
+// or induce enzymes as well.
 type Enzyme struct {
-	UNIPROTID string `xml:"uniprot-id" json:"uniprot0id"`
+	BioEntity
+	InhibitionStrength string `xml:"inhibition-strength"`
+	InductionStrength  string `xml:"induction-strength"`
 }
 
 // ExternalIdentifier is an identifier to
@@ -813,8 +301,8 @@ type Packager struct {
 type Patent struct {
 	Number    string `xml:"patent>number" json:"number"`
 	Country   string `xml:"patent>country" json:"country"`
-	Approved  string `xml:"patent>approved" json:"approved"`
-	Expires   string `xml:"patent>expires" json:"expiration"`
+	Approved  Date   `xml:"patent>approved" json:"approved"`
+	Expires   Date   `xml:"patent>expires" json:"expiration"`
 	Pediatric bool   `xml:"patent>pediatric-extension" json:"pediatric"`
 }
 
@@ -829,7 +317,7 @@ type Pathway struct {
 	Name     string        `xml:"name"`
 	Category string        `xml:"category"`
 	Drugs    []PathwayDrug `xml:"drugs>drug"`
-	Enzymes  []Enzyme      `xml:"enzymes"`
+	Enzymes  []string      `xml:"enzymes>uniprot-id"` // UniProt IDs
 }
 
 // PathwayDrug identifies drugs involved with pathways
@@ -846,19 +334,20 @@ type Pfam struct {
 
 // Polypeptide represents a single polypeptide and its relative details
 type Polypeptide struct {
-	ID                 string `xml:"id,attr"`
-	Source             string `xml:"source,attr"`
-	Name               string `xml:"name"`
-	GeneralFunction    string `xml:"general-function"`
-	SpecificFunction   string `xml:"specific-function"`
-	GeneName           string `xml:"gene-name"`
-	Locus              string `xml:"locus"`
-	CellularLocation   string `xml:"cellular-location"`
-	SignalRegion       string `xml:"signal-regions"`
-	TheoreticalPi      string `xml:"theoretical-pi"`
-	MolecularWeight    string `xml:"molecular-weight"`
-	ChromosomeLocation string `xml:"chromosome-location"`
-	OrganismTaxonomy   struct {
+	ID                   string `xml:"id,attr"`
+	Source               string `xml:"source,attr"`
+	Name                 string `xml:"name"`
+	GeneralFunction      string `xml:"general-function"`
+	SpecificFunction     string `xml:"specific-function"`
+	GeneName             string `xml:"gene-name"`
+	Locus                string `xml:"locus"`
+	CellularLocation     string `xml:"cellular-location"`
+	TransmembraneRegions string `xml:"transmembrane-regions"`
+	SignalRegion         string `xml:"signal-regions"`
+	TheoreticalPi        string `xml:"theoretical-pi"`
+	MolecularWeight      string `xml:"molecular-weight"`
+	ChromosomeLocation   string `xml:"chromosome-location"`
+	OrganismTaxonomy     struct {
 		TaxonomyID string `xml:"ncbi-taxonomy-id,attr"`
 		Organism   string `xml:",chardata"`
 	} `xml:"organism"`
@@ -895,8 +384,8 @@ type Product struct {
 	DPDID                string `xml:"product>dpd-id" json:"dpd-id"`
 	EMAProductCode       string `xml:"product>ema-product-code" json:"ema-product-code"`
 	EMAProductNumber     string `xml:"product>ema-ma-number" json:"ema-product-number"`
-	StartedMarketing     string `xml:"product>started-marketing-on" json:"started-marketing-on"`
-	EndedMarketing       string `xml:"product>ended-marketing-on" json:"ended-marketing-on"`
+	StartedMarketing     Date   `xml:"product>started-marketing-on" json:"started-marketing-on"`
+	EndedMarketing       Date   `xml:"product>ended-marketing-on" json:"ended-marketing-on"`
 	DosageForm           string `xml:"product>dosage-form" json:"dosage-form"`
 	Strength             string `xml:"product>strength" json:"strngth"`
 	Route                string `xml:"product>route" json:"route"`
@@ -908,7 +397,9 @@ type Product struct {
 	Source               string `xml:"product>source" json:"source"`
 }
 
-// Property represents a property of a drug as recorded in the source
+// Property represents a property of a drug as recorded in the source,
+// either measured experimentally or calculated from its structure
+// (e.g. SMILES, InChI, logP, Rule of Five)
 type Property struct {
 	Kind   string `xml:"kind" json:"kind"`
 	Value  string `xml:"value" json:"value"`
@@ -981,10 +472,12 @@ type Synonym struct {
 // Drug targets are most commonly proteins such as enzymes,
 // ion channels, and receptors.
 type Target struct {
-	ID          string      `xml:"id"`
-	Organism    string      `xml:"organism"`
-	Actions     []string    `xml:"actions>action"`
-	References  []Reference `xml:"references"`
-	KnownAction string      `xml:"known-action"`
-	Polypeptide Polypeptide `xml:"polypeptide"`
+	BioEntity
+}
+
+// Transporter represents a membrane bound protein which shuttles
+// ions, small molecules or macromolecules, such as drugs,
+// across the membrane of a cell.
+type Transporter struct {
+	BioEntity
 }
@@ -0,0 +1,77 @@
+package drugbank
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestDrugIDs(t *testing.T) {
+	tests := []struct {
+		name      string
+		ids       string
+		primary   string
+		secondary []string
+	}{
+		{
+			name:      "primary first",
+			ids:       `<drugbank-id primary="true">DB00001</drugbank-id><drugbank-id>BTD00024</drugbank-id><drugbank-id>BIOD00024</drugbank-id>`,
+			primary:   "DB00001",
+			secondary: []string{"BTD00024", "BIOD00024"},
+		},
+		{
+			name:      "primary last",
+			ids:       `<drugbank-id>APRD00001</drugbank-id><drugbank-id primary="true">DB00002</drugbank-id>`,
+			primary:   "DB00002",
+			secondary: []string{"APRD00001"},
+		},
+		{
+			name:      "no primary",
+			ids:       `<drugbank-id>DB00003</drugbank-id><drugbank-id>APRD00002</drugbank-id>`,
+			primary:   "DB00003",
+			secondary: []string{"APRD00002"},
+		},
+		{
+			name:    "single",
+			ids:     `<drugbank-id primary="true">DB00004</drugbank-id>`,
+			primary: "DB00004",
+		},
+		{
+			name:      "repeated primary",
+			ids:       `<drugbank-id primary="true">DB00005</drugbank-id><drugbank-id>DB00005</drugbank-id><drugbank-id>BTD00001</drugbank-id>`,
+			primary:   "DB00005",
+			secondary: []string{"BTD00001"},
+		},
+		{name: "none"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var d Drug
+			if err := xml.Unmarshal([]byte(`<drug>`+test.ids+`<name>Lepirudin</name></drug>`), &d); err != nil {
+				t.Fatal(err)
+			}
+			if d.ID != test.primary {
+				t.Errorf("ID %q, want %q", d.ID, test.primary)
+			}
+			if strings.Join(d.SecondaryIDs, ",") != strings.Join(test.secondary, ",") {
+				t.Errorf("secondary IDs %v, want %v", d.SecondaryIDs, test.secondary)
+			}
+
+			// every legacy ID is an alias of the primary one
+			var aliases []string
+			for _, row := range Rows(&d) {
+				if row.Table != "drug_ids" {
+					continue
+				}
+				alias := row.Value.(drugIDRow)
+				if alias.DrugID != test.primary {
+					t.Errorf("%s aliases %s, want %s", alias.LegacyID, alias.DrugID, test.primary)
+				}
+				aliases = append(aliases, alias.LegacyID)
+			}
+			if strings.Join(aliases, ",") != strings.Join(test.secondary, ",") {
+				t.Errorf("drug_ids %v, want %v", aliases, test.secondary)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package drugbank
+
+import (
+	"fmt"
+)
+
+// DecodeError is returned by a Pipeline when a drug cannot be read,
+// and listed in the Record of a drug for its fields holding invalid
+// values. It locates the failing drug both by position and by byte
+// offset, and the element being read when the error occurred.
+type DecodeError struct {
+	Index  int    // position of the drug in the document
+	Offset int64  // byte offset of the drug element in the input
+	Path   string // path of the failing element, e.g. drugbank/drug/prices/price/cost
+	ID     string // DrugBank ID of the drug, if it could be read
+	Err    error
+
+	// Recoverable is true when the drug element was read in full and
+	// only its decoding failed, so the rest of the document can still be read.
+	// Syntax and I/O errors (e.g. truncated files) are not recoverable.
+	Recoverable bool
+}
+
+func (e *DecodeError) Error() string {
+	drug := fmt.Sprintf("drug %d", e.Index)
+	if e.ID != "" {
+		drug += " (" + e.ID + ")"
+	}
+	return fmt.Sprintf("%s at offset %d, %s: %v", drug, e.Offset, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,107 @@
+package drugbank
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeError(t *testing.T) {
+	fixture := string(readFixture(t, 1))
+	// start of the third drug of the fixture
+	third := strings.Index(fixture, `<drug type="small molecule" created="2005-06-13" updated="2017-01-01">`)
+	invalidDate := strings.Replace(brokenDrug, `updated="2019-01-01"`, `updated="01/01/2019"`, 1)
+	invalidDate = strings.Replace(invalidDate, "not a number", "12.5", 1)
+
+	tests := []struct {
+		name     string
+		document string
+		want     DecodeError
+		drugs    int // delivered with a Reject hook
+	}{
+		{
+			name:     "invalid cost",
+			document: string(readFixture(t, 1, brokenDrug)),
+			want: DecodeError{
+				Index:       3,
+				Offset:      int64(strings.LastIndex(fixture, "</drugbank>")),
+				Path:        "drugbank/drug/prices/price/cost",
+				ID:          "DB99999",
+				Recoverable: true,
+			},
+			drugs: 3,
+		},
+		{
+			name:     "invalid attribute",
+			document: string(readFixture(t, 1, invalidDate)),
+			want: DecodeError{
+				Index:       3,
+				Offset:      int64(strings.LastIndex(fixture, "</drugbank>")),
+				Path:        "drugbank/drug",
+				ID:          "DB99999",
+				Recoverable: true,
+			},
+			drugs: 3,
+		},
+		{
+			name:     "truncated",
+			document: fixture[:strings.Index(fixture, "<name>Dornase")+len("<name>Dornase")],
+			want: DecodeError{
+				Index:  2,
+				Offset: int64(third),
+				Path:   "drugbank/drug/name",
+			},
+			drugs: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, workers := range []int{1, 4} {
+				err := NewPipeline(strings.NewReader(test.document), workers).Run(func(*Record) error { return nil })
+				var decodeErr *DecodeError
+				if !errors.As(err, &decodeErr) {
+					t.Fatalf("%d workers: error %v, want a DecodeError", workers, err)
+				}
+				if decodeErr.Err == nil {
+					t.Errorf("%d workers: no underlying error", workers)
+				}
+				got := *decodeErr
+				got.Err = nil
+				if got != test.want {
+					t.Errorf("%d workers: error %+v, want %+v", workers, got, test.want)
+				}
+
+				// with a Reject hook, only recoverable errors are rejected
+				pipeline := NewPipeline(strings.NewReader(test.document), workers)
+				var rejected []*DecodeError
+				var elements [][]byte
+				pipeline.Reject = func(err *DecodeError, element []byte) error {
+					rejected = append(rejected, err)
+					elements = append(elements, element)
+					return nil
+				}
+				drugs := 0
+				err = pipeline.Run(func(*Record) error { drugs++; return nil })
+				if drugs != test.drugs {
+					t.Errorf("%d workers: %d drugs delivered, want %d", workers, drugs, test.drugs)
+				}
+				if !test.want.Recoverable {
+					if !errors.As(err, &decodeErr) || len(rejected) > 0 {
+						t.Errorf("%d workers: error %v and %d drugs rejected, want the DecodeError", workers, err, len(rejected))
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("%d workers: %v", workers, err)
+				}
+				if len(rejected) != 1 || rejected[0].Index != test.want.Index || rejected[0].Path != test.want.Path {
+					t.Fatalf("%d workers: rejected %v, want drug %d", workers, rejected, test.want.Index)
+				}
+				if !bytes.Contains(elements[0], []byte("DB99999")) {
+					t.Errorf("%d workers: rejected xml %s does not hold the drug", workers, elements[0])
+				}
+			}
+		})
+	}
+}
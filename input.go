@@ -0,0 +1,190 @@
+package drugbank
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ulikunitz/xz"
+)
+
+// magic numbers of the compressed formats detected by OpenInput
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zipMagic   = []byte("PK\x03\x04")
+)
+
+// Input is a drugbank xml document opened by OpenInput. Reading it
+// returns the xml, decompressed if the file is compressed.
+type Input struct {
+	io.Reader
+	// Size is the size of the file in bytes, as stored on disk,
+	// or -1 if unknown, e.g. when reading from stdin
+	Size int64
+	// Compressed is set if the file is compressed or archived, in which
+	// case offsets in the xml do not match offsets in the file
+	Compressed bool
+	read       int64 // bytes read from the file, accessed atomically
+	file       io.Reader
+	hash       hash.Hash // of the bytes read from file
+	archive    *os.File  // zip archive, read at offsets
+	closers    []io.Closer
+}
+
+// OpenInput opens the drugbank dataset at path, which may be an xml
+// file, the same compressed with gzip, bzip2 or xz, or a zip archive
+// holding it, as DrugBank distributes it. The format is detected from
+// the contents of the file. A path of "-" reads from stdin, which cannot
+// hold a zip archive. The file is read once, from start to end.
+func OpenInput(path string) (*Input, error) {
+	in := &Input{Size: -1, hash: sha256.New()}
+	var file io.Reader
+	if path == "-" {
+		file = os.Stdin
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		in.closers = append(in.closers, f)
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			in.Size = info.Size()
+		}
+		file = f
+	}
+	in.file = &countingReader{file, &in.read, in.hash}
+	buffer := bufio.NewReaderSize(in.file, 64*1024)
+	magic, err := buffer.Peek(6)
+	if err != nil && err != io.EOF {
+		in.Close()
+		return nil, err
+	}
+
+	in.Compressed = true
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		r, err := gzip.NewReader(buffer)
+		if err != nil {
+			in.Close()
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		in.Reader = r
+	case bytes.HasPrefix(magic, bzip2Magic):
+		in.Reader = bzip2.NewReader(buffer)
+	case bytes.HasPrefix(magic, xzMagic):
+		r, err := xz.NewReader(buffer)
+		if err != nil {
+			in.Close()
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		in.Reader = r
+	case bytes.HasPrefix(magic, zipMagic):
+		f, ok := file.(*os.File)
+		if !ok || in.Size < 0 {
+			in.Close()
+			return nil, errors.New("zip archives cannot be read from stdin, pipe the xml instead (e.g. unzip -p)")
+		}
+		// the archive is read at offsets, from its directory at the end
+		atomic.StoreInt64(&in.read, 0)
+		r, err := openZip(f, in.Size, &in.read)
+		if err != nil {
+			in.Close()
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		in.Reader = r
+		in.archive = f
+		in.closers = append(in.closers, r)
+	default:
+		in.Reader = buffer
+		in.Compressed = false
+	}
+	return in, nil
+}
+
+// openZip opens the first xml file of a zip archive
+func openZip(f *os.File, size int64, read *int64) (io.ReadCloser, error) {
+	archive, err := zip.NewReader(&countingReaderAt{f, read}, size)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range archive.File {
+		if strings.EqualFold(path.Ext(entry.Name), ".xml") {
+			return entry.Open()
+		}
+	}
+	return nil, errors.New("no xml file in zip archive")
+}
+
+// Offset returns the number of bytes read from the file so far, which
+// may be ahead of the xml decoded as the file is read in blocks
+func (in *Input) Offset() int64 {
+	return atomic.LoadInt64(&in.read)
+}
+
+// SHA256 returns the hex encoded SHA-256 checksum of the file, once the
+// document has been read. The rest of the file, e.g. trailing whitespace,
+// is read to compute it. Zip archives, which are not read sequentially,
+// are read again.
+func (in *Input) SHA256() (string, error) {
+	if in.archive != nil {
+		in.hash.Reset()
+		if _, err := io.Copy(in.hash, io.NewSectionReader(in.archive, 0, in.Size)); err != nil {
+			return "", err
+		}
+	} else if _, err := io.Copy(ioutil.Discard, in.file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(in.hash.Sum(nil)), nil
+}
+
+// Close closes the file
+func (in *Input) Close() error {
+	var firstErr error
+	for i := len(in.closers) - 1; i >= 0; i-- {
+		if err := in.closers[i].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// countingReader counts and hashes the bytes read from a reader
+type countingReader struct {
+	reader io.Reader
+	read   *int64
+	hash   io.Writer
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	atomic.AddInt64(r.read, int64(n))
+	r.hash.Write(p[:n])
+	return n, err
+}
+
+// countingReaderAt counts the bytes read from a file read at offsets
+type countingReaderAt struct {
+	reader io.ReaderAt
+	read   *int64
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	n, err := r.reader.ReadAt(p, offset)
+	atomic.AddInt64(r.read, int64(n))
+	return n, err
+}
@@ -0,0 +1,210 @@
+package drugbank
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ulikunitz/xz"
+)
+
+const inputXML = `<drugbank><drug><drugbank-id primary="true">DB00001</drugbank-id><name>Lepirudin</name></drug></drugbank>
+`
+
+// bzip2Input is inputXML compressed with bzip2 -9, which the standard
+// library can read but not write
+const bzip2Input = "" +
+	"\x42\x5a\x68\x39\x31\x41\x59\x26\x53\x59\x24\x35\x77\x8a\x00\x00" +
+	"\x0b\x5d\x80\x40\x10\x50\x02\xe0\x07\x14\x04\x36\xab\x56\x20\x20" +
+	"\x00\x48\x4a\xa7\xea\x83\x4f\x49\xa6\x40\x62\x1a\x09\x48\x86\x8c" +
+	"\xd4\x06\x26\x47\xa8\x76\x9b\xf1\xaf\x64\x41\xd7\x49\x9a\x4a\x88" +
+	"\x82\xb3\xe1\x3b\xd4\x76\xc7\x9c\xc7\x93\x0a\xb0\x54\xb5\x3a\x10" +
+	"\xe7\xe4\x5d\xe2\x5c\x6e\x1b\x50\x93\x0e\x55\xc0\x39\x6c\xe1\xf4" +
+	"\x44\x85\x15\x03\xf1\x77\x24\x53\x85\x09\x02\x43\x57\x78\xa0"
+
+// compress returns inputXML in the given format
+func compress(t *testing.T, format string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	var w io.WriteCloser
+	switch format {
+	case "xml":
+		return []byte(inputXML)
+	case "bzip2":
+		return []byte(bzip2Input)
+	case "gzip":
+		w = gzip.NewWriter(&buffer)
+	case "xz":
+		var err error
+		if w, err = xz.NewWriter(&buffer); err != nil {
+			t.Fatal(err)
+		}
+	case "zip":
+		archive := zip.NewWriter(&buffer)
+		// the first xml file of the archive is read
+		for _, entry := range []struct{ name, text string }{
+			{"README.txt", "DrugBank full database"},
+			{"full database.XML", inputXML},
+			{"other.xml", strings.Replace(inputXML, "Lepirudin", "Cetuximab", -1)},
+		} {
+			f, err := archive.Create(entry.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.WriteString(f, entry.text); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := archive.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buffer.Bytes()
+	}
+	if _, err := io.WriteString(w, inputXML); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// writeInput writes data to a file of dir, returning its path
+func writeInput(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, data, 0664); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// checkInput reads in to the end and checks that it holds inputXML,
+// and that the whole of data was read and hashed
+func checkInput(t *testing.T, in *Input, data []byte) {
+	t.Helper()
+	text, err := ioutil.ReadAll(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != inputXML {
+		t.Errorf("read %q, want %q", text, inputXML)
+	}
+	sum, err := in.SHA256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.Sum256(data)
+	if sum != hex.EncodeToString(want[:]) {
+		t.Errorf("SHA-256 %s, want %x", sum, want)
+	}
+	// zip archives are read at offsets, their directory along with
+	// the entry, so the bytes counted only match the other files
+	if in.archive != nil {
+		if in.Offset() <= 0 {
+			t.Errorf("%d bytes read from the archive", in.Offset())
+		}
+	} else if in.Offset() != int64(len(data)) {
+		t.Errorf("%d bytes read, want %d", in.Offset(), len(data))
+	}
+}
+
+func TestOpenInput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, format := range []string{"xml", "gzip", "bzip2", "xz", "zip"} {
+		t.Run(format, func(t *testing.T) {
+			data := compress(t, format)
+			// the format is detected from the contents, not the name
+			in, err := OpenInput(writeInput(t, dir, format+".dat", data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer in.Close()
+			if in.Size != int64(len(data)) {
+				t.Errorf("size %d, want %d", in.Size, len(data))
+			}
+			if in.Compressed != (format != "xml") {
+				t.Errorf("compressed: %v", in.Compressed)
+			}
+			checkInput(t, in, data)
+		})
+	}
+}
+
+func TestOpenInputStdin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+	for _, format := range []string{"xml", "gzip", "zip"} {
+		t.Run(format, func(t *testing.T) {
+			data := compress(t, format)
+			f, err := os.Open(writeInput(t, dir, format+".dat", data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			os.Stdin = f
+			in, err := OpenInput("-")
+			if format == "zip" {
+				if err == nil {
+					in.Close()
+					t.Error("zip archive read from stdin")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer in.Close()
+			if in.Size != -1 {
+				t.Errorf("size %d, want -1", in.Size)
+			}
+			checkInput(t, in, data)
+		})
+	}
+}
+
+func TestOpenInputErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	if _, err := w.Create("README.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{
+		"no xml.zip":   archive.Bytes(),
+		"truncated.gz": compress(t, "gzip")[:5],
+		"truncated.xz": compress(t, "xz")[:8],
+	} {
+		if in, err := OpenInput(writeInput(t, dir, name, data)); err == nil {
+			in.Close()
+			t.Errorf("%s opened", name)
+		}
+	}
+	if _, err := OpenInput(filepath.Join(dir, "missing.xml")); !os.IsNotExist(err) {
+		t.Errorf("missing file opened: %v", err)
+	}
+}
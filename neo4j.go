@@ -0,0 +1,305 @@
+package drugbank
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// neo4j-admin import types of the column kinds, strings need none
+var neo4jTypes = map[Kind]string{
+	KindBool:  ":boolean",
+	KindInt:   ":long",
+	KindFloat: ":double",
+	KindDate:  ":date",
+}
+
+// neo4jRecord is a line of a neo4j-admin import file,
+// along with the header of the file
+type neo4jRecord struct {
+	header []string
+	values []string
+}
+
+// neo4jEncoder writes neo4jRecords as csv, the header first
+type neo4jEncoder struct {
+	writer  *csv.Writer
+	started bool
+}
+
+func (e *neo4jEncoder) Encode(row interface{}) error {
+	record := row.(neo4jRecord)
+	if !e.started {
+		e.started = true
+		if err := e.writer.Write(record.header); err != nil {
+			return err
+		}
+	}
+	return e.writer.Write(record.values)
+}
+
+func (e *neo4jEncoder) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// neo4jFile is a node or relationship file written from the rows of a table
+type neo4jFile struct {
+	name   string
+	header []string
+	id     Column // ID of nodes, start ID of relationships
+	end    Column // end ID of relationships
+	label  string // label of nodes, type of relationships
+	values []Column
+}
+
+// record returns the line of the file for row, or false if
+// row lacks the IDs of the node or relationship
+func (f *neo4jFile) record(row interface{}, node bool) (neo4jRecord, bool) {
+	values := []string{f.id.String(row)}
+	if values[0] == "" {
+		return neo4jRecord{}, false
+	}
+	if !node {
+		end := f.end.String(row)
+		if end == "" {
+			return neo4jRecord{}, false
+		}
+		values = append(values, end)
+	}
+	values = append(values, f.label)
+	for _, column := range f.values {
+		values = append(values, column.String(row))
+	}
+	return neo4jRecord{f.header, values}, true
+}
+
+// neo4jTable lists the files written from the rows of a table
+type neo4jTable struct {
+	nodes         []*neo4jFile
+	relationships []*neo4jFile
+}
+
+// neo4jWriter writes the vertices and edges of the graph described
+// by Tables as node and relationship files for neo4j-admin import.
+// Rows of tables that are not part of the graph are dropped.
+type neo4jWriter struct {
+	files   *fileWriter
+	tables  map[string]*neo4jTable
+	seen    map[string]map[string]bool // IDs of the nodes written, by label
+	scripts []string                   // written on Close
+}
+
+// NewNeo4jWriter returns a TableWriter writing the graph described
+// by Tables as csv files for neo4j-admin database import: a file of
+// nodes named <Label>.csv for each vertex type, and a file of
+// relationships named <TYPE>_<table>.csv for each edge of a table.
+// Nodes are written once per ID. On Close, an import.sh script running
+// the import and a constraints.cypher script are written along with them.
+func NewNeo4jWriter(dir string) TableWriter {
+	return &neo4jWriter{
+		files: &fileWriter{
+			dir:       dir,
+			extension: ".csv",
+			newEncoder: func(w io.Writer) encoder {
+				return &neo4jEncoder{writer: csv.NewWriter(w)}
+			},
+			tables: map[string]*tableFile{},
+		},
+		tables: map[string]*neo4jTable{},
+		seen:   map[string]map[string]bool{},
+	}
+}
+
+// neo4jProperty returns the header field of a column stored as a property
+func neo4jProperty(column Column) string {
+	return neo4jName(column.Name) + neo4jTypes[column.Kind]
+}
+
+// neo4jName turns a column name into a property name
+func neo4jName(name string) string {
+	return strings.Replace(name, "-", "_", -1)
+}
+
+// neo4jRelationship returns the relationship type of an edge
+func neo4jRelationship(edge Edge) string {
+	return strings.ToUpper(edge.Type)
+}
+
+// table returns the files written from the rows of the named table,
+// or nil if the table is not part of the graph
+func (w *neo4jWriter) table(name string) (*neo4jTable, error) {
+	if t, ok := w.tables[name]; ok {
+		return t, nil
+	}
+	table, ok := LookupTable(name)
+	if !ok || (len(table.Vertices) == 0 && len(table.Edges) == 0) {
+		w.tables[name] = nil
+		return nil, nil
+	}
+	t := &neo4jTable{}
+	for _, vertex := range table.Vertices {
+		id, ok := table.Column(vertex.ID)
+		if !ok {
+			return nil, fmt.Errorf("table %s has no column %s", table.Name, vertex.ID)
+		}
+		f := &neo4jFile{
+			name:   vertex.Type,
+			header: []string{fmt.Sprintf("%s:ID(%s)", neo4jName(id.Name), vertex.Type), ":LABEL"},
+			id:     id,
+			label:  vertex.Type,
+			values: table.VertexAttributes(vertex),
+		}
+		for _, column := range f.values {
+			f.header = append(f.header, neo4jProperty(column))
+		}
+		t.nodes = append(t.nodes, f)
+	}
+	for _, edge := range table.Edges {
+		start, ok := table.Column(edge.FromID)
+		if !ok {
+			return nil, fmt.Errorf("table %s has no column %s", table.Name, edge.FromID)
+		}
+		end, ok := table.Column(edge.ToID)
+		if !ok {
+			return nil, fmt.Errorf("table %s has no column %s", table.Name, edge.ToID)
+		}
+		f := &neo4jFile{
+			name: neo4jRelationship(edge) + "_" + table.Name,
+			header: []string{
+				fmt.Sprintf(":START_ID(%s)", edge.From),
+				fmt.Sprintf(":END_ID(%s)", edge.To),
+				":TYPE",
+			},
+			id:     start,
+			end:    end,
+			label:  neo4jRelationship(edge),
+			values: table.EdgeAttributes(edge),
+		}
+		for _, column := range f.values {
+			f.header = append(f.header, neo4jProperty(column))
+		}
+		t.relationships = append(t.relationships, f)
+	}
+	w.tables[name] = t
+	return t, nil
+}
+
+func (w *neo4jWriter) Write(table string, row interface{}) error {
+	t, err := w.table(table)
+	if t == nil || err != nil {
+		return err
+	}
+	for _, f := range t.nodes {
+		record, ok := f.record(row, true)
+		if !ok {
+			continue
+		}
+		if w.seen[f.label] == nil {
+			w.seen[f.label] = map[string]bool{}
+		}
+		if w.seen[f.label][record.values[0]] {
+			continue
+		}
+		w.seen[f.label][record.values[0]] = true
+		if err := w.files.Write(f.name, record); err != nil {
+			return err
+		}
+	}
+	for _, f := range t.relationships {
+		if record, ok := f.record(row, false); ok {
+			if err := w.files.Write(f.name, record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *neo4jWriter) Close() error {
+	var nodes, relationships []string
+	for _, name := range w.files.order {
+		if _, ok := w.seen[name]; ok {
+			nodes = append(nodes, name+".csv")
+		} else {
+			relationships = append(relationships, name+".csv")
+		}
+	}
+	dir := w.files.dir
+	if err := w.files.Close(); err != nil {
+		return err
+	}
+	importScript := filepath.Join(dir, "import.sh")
+	if err := WriteFile(importScript, 0775, func(out io.Writer) error {
+		return writeNeo4jImport(out, nodes, relationships)
+	}); err != nil {
+		return err
+	}
+	w.scripts = append(w.scripts, importScript)
+	constraints := filepath.Join(dir, "constraints.cypher")
+	if err := WriteFile(constraints, 0664, func(out io.Writer) error {
+		return WriteNeo4jConstraints(out, Tables)
+	}); err != nil {
+		return err
+	}
+	w.scripts = append(w.scripts, constraints)
+	return nil
+}
+
+// Files returns the node and relationship files, followed by the scripts
+func (w *neo4jWriter) Files() []string {
+	return append(append([]string{}, w.files.Files()...), w.scripts...)
+}
+
+// writeNeo4jImport writes a shell script importing the node and
+// relationship files into a new database, named after its first argument.
+// Relationships to nodes missing from the dataset (e.g. interactions
+// with drugs that are not part of it) are skipped, and fields may span
+// several lines, as descriptions do.
+func writeNeo4jImport(w io.Writer, nodes, relationships []string) error {
+	buffer := bufio.NewWriter(w)
+	fmt.Fprintln(buffer, "#!/bin/sh")
+	fmt.Fprintln(buffer, "# Generated by drugbank parse --format neo4j.")
+	fmt.Fprintln(buffer, "# Run it with the name of the database to create, then run constraints.cypher.")
+	fmt.Fprintln(buffer, `cd "$(dirname "$0")" || exit 1`)
+	fmt.Fprintln(buffer, "neo4j-admin database import full --skip-bad-relationships --multiline-fields=true \\")
+	for _, name := range nodes {
+		fmt.Fprintf(buffer, "\t--nodes=%s \\\n", name)
+	}
+	for _, name := range relationships {
+		fmt.Fprintf(buffer, "\t--relationships=%s \\\n", name)
+	}
+	fmt.Fprintln(buffer, `	"${1:-neo4j}"`)
+	return buffer.Flush()
+}
+
+// WriteNeo4jConstraints writes a Cypher script creating a uniqueness
+// constraint on the ID of every vertex type of the graph described by
+// tables, and an index on their names
+func WriteNeo4jConstraints(w io.Writer, tables []Table) error {
+	buffer := bufio.NewWriter(w)
+	fmt.Fprintln(buffer, "// Generated by drugbank, do not edit.")
+	seen := map[string]bool{}
+	for _, table := range tables {
+		for _, vertex := range table.Vertices {
+			if seen[vertex.Type] {
+				continue
+			}
+			seen[vertex.Type] = true
+			label := strings.ToLower(vertex.Type)
+			id := neo4jName(vertex.ID)
+			fmt.Fprintf(buffer, "CREATE CONSTRAINT %s_%s IF NOT EXISTS FOR (n:%s) REQUIRE n.%s IS UNIQUE;\n",
+				label, id, vertex.Type, id)
+			for _, column := range table.VertexAttributes(vertex) {
+				if column.Name == "name" {
+					fmt.Fprintf(buffer, "CREATE INDEX %s_name IF NOT EXISTS FOR (n:%s) ON (n.name);\n",
+						label, vertex.Type)
+				}
+			}
+		}
+	}
+	return buffer.Flush()
+}
@@ -0,0 +1,121 @@
+package drugbank
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNeo4jWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neo4j")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	patent := Patent{Number: "5180668", Country: "United States",
+		Approved: Date{Time: time.Date(1993, 1, 19, 0, 0, 0, 0, time.UTC)},
+		Expires:  Date{Time: time.Date(2010, 1, 19, 0, 0, 0, 0, time.UTC)}}
+	prothrombin := polypeptideRow{UNIPROTID: "P00734", Source: "Swiss-Prot", Name: "Prothrombin", GeneName: "F2",
+		Locus: "11p11", Organism: "Humans", TaxonomyID: "9606"}
+	rows := []Row{
+		{Table: "patents", Value: patentRow{"DB00001", patent}},
+		// a node is written once, the relationships to it once per row
+		{Table: "patents", Value: patentRow{"DB00002", patent}},
+		{Table: "polypeptides", Value: prothrombin},
+		{Table: "polypeptides", Value: prothrombin},
+		{Table: "pathways", Value: pathwayRow{"SMP0000278", "Lepirudin Action Pathway", "drug_action"}},
+		{Table: "pathway_enzymes", Value: pathwayEnzymeRow{"SMP0000278", "P00734"}},
+		// relationships missing an end are dropped
+		{Table: "pathway_enzymes", Value: pathwayEnzymeRow{"SMP0000278", ""}},
+		// tables that are not part of the graph are dropped
+		{Table: "drug_targets", Value: bioEntityRow{DrugID: "DB00001", BioEntityID: "BE0000048"}},
+	}
+	writer := NewNeo4jWriter(dir)
+	for _, row := range rows {
+		if err := writer.Write(row.Table, row.Value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"Patent.csv": "number:ID(Patent),:LABEL,country,approved:date,expiration:date,pediatric:boolean\n" +
+			"5180668,Patent,United States,1993-01-19,2010-01-19,false\n",
+		"REFERRED_BY_patents.csv": ":START_ID(Drug),:END_ID(Patent),:TYPE\n" +
+			"DB00001,5180668,REFERRED_BY\n" +
+			"DB00002,5180668,REFERRED_BY\n",
+		"Polypeptide.csv": "uniprot_id:ID(Polypeptide),:LABEL,name,gene_name,organism\n" +
+			"P00734,Polypeptide,Prothrombin,F2,Humans\n",
+		"Pathway.csv": "smpdb_id:ID(Pathway),:LABEL,name,category\n" +
+			"SMP0000278,Pathway,Lepirudin Action Pathway,drug_action\n",
+		"PARTICIPATES_IN_pathway_enzymes.csv": ":START_ID(Polypeptide),:END_ID(Pathway),:TYPE\n" +
+			"P00734,SMP0000278,PARTICIPATES_IN\n",
+		// nodes are imported first, in the order their files were opened
+		"import.sh": "#!/bin/sh\n" +
+			"# Generated by drugbank parse --format neo4j.\n" +
+			"# Run it with the name of the database to create, then run constraints.cypher.\n" +
+			"cd \"$(dirname \"$0\")\" || exit 1\n" +
+			"neo4j-admin database import full --skip-bad-relationships --multiline-fields=true \\\n" +
+			"\t--nodes=Patent.csv \\\n" +
+			"\t--nodes=Polypeptide.csv \\\n" +
+			"\t--nodes=Pathway.csv \\\n" +
+			"\t--relationships=REFERRED_BY_patents.csv \\\n" +
+			"\t--relationships=PARTICIPATES_IN_pathway_enzymes.csv \\\n" +
+			"\t\"${1:-neo4j}\"\n",
+	}
+	for name, want := range want {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s:\n%s\nwant\n%s", name, data, want)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dir, "import.sh")); err != nil || info.Mode()&0100 == 0 {
+		t.Errorf("import.sh not executable: %v", err)
+	}
+
+	files := writer.(FileLister).Files()
+	if len(files) != 7 || filepath.Base(files[5]) != "import.sh" || filepath.Base(files[6]) != "constraints.cypher" {
+		t.Errorf("files %v, want 5 csv files and the scripts", files)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 7 {
+		t.Errorf("%d files written, want 7", len(entries))
+	}
+}
+
+func TestWriteNeo4jConstraints(t *testing.T) {
+	var tables []Table
+	for _, name := range []string{"drugs", "products", "polypeptides", "drugs"} {
+		table, ok := LookupTable(name)
+		if !ok {
+			t.Fatalf("no table %s", name)
+		}
+		tables = append(tables, table)
+	}
+	var script bytes.Buffer
+	if err := WriteNeo4jConstraints(&script, tables); err != nil {
+		t.Fatal(err)
+	}
+	// each vertex type is constrained once, and named ones are indexed
+	// by name unless the name is their ID
+	want := "// Generated by drugbank, do not edit.\n" +
+		"CREATE CONSTRAINT drug_drugbank_id IF NOT EXISTS FOR (n:Drug) REQUIRE n.drugbank_id IS UNIQUE;\n" +
+		"CREATE INDEX drug_name IF NOT EXISTS FOR (n:Drug) ON (n.name);\n" +
+		"CREATE CONSTRAINT product_name IF NOT EXISTS FOR (n:Product) REQUIRE n.name IS UNIQUE;\n" +
+		"CREATE CONSTRAINT polypeptide_uniprot_id IF NOT EXISTS FOR (n:Polypeptide) REQUIRE n.uniprot_id IS UNIQUE;\n" +
+		"CREATE INDEX polypeptide_name IF NOT EXISTS FOR (n:Polypeptide) ON (n.name);\n"
+	if script.String() != want {
+		t.Errorf("constraints:\n%s\nwant\n%s", script.String(), want)
+	}
+}
@@ -0,0 +1,180 @@
+// Package parquet writes the drugbank output tables as Apache Parquet
+// files, one per table, with columns typed after the kinds of the
+// table columns: strings, booleans, 64 bit integers, doubles and
+// timestamps in milliseconds for dates, which are null when missing.
+package parquet
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	pq "github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+
+	drugbank "github.com/iz4vve/drugbank-dataset-parser"
+)
+
+// Compressions lists the supported compression codecs
+var Compressions = []string{"uncompressed", "snappy", "gzip", "lz4", "zstd"}
+
+// DefaultRowGroupSize is the size of the row groups of the files, in bytes
+// of compressed pages. Pages are held in memory until a group is full, for
+// every table at once: with some 50 tables open, groups are kept small to
+// bound the memory used to about 200MB.
+const DefaultRowGroupSize = 4 * 1024 * 1024
+
+// metadata of the column kinds, as understood by parquet-go
+var types = map[drugbank.Kind]string{
+	drugbank.KindString: "type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REQUIRED",
+	drugbank.KindBool:   "type=BOOLEAN, repetitiontype=REQUIRED",
+	drugbank.KindInt:    "type=INT64, repetitiontype=REQUIRED",
+	drugbank.KindFloat:  "type=DOUBLE, repetitiontype=REQUIRED",
+	drugbank.KindDate:   "type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL",
+}
+
+// Writer is a drugbank.TableWriter writing every table as a Parquet
+// file named <table>.parquet in a directory. Files are created the
+// first time a row is written to their table and completed on Close.
+type Writer struct {
+	dir          string
+	compression  pq.CompressionCodec
+	RowGroupSize int64
+	tables       map[string]*table
+	order        []string
+	files        []string // created so far
+}
+
+// table is an open Parquet file
+type table struct {
+	file    *os.File
+	buffer  *bufio.Writer
+	writer  *writer.CSVWriter
+	columns []drugbank.Column
+}
+
+// NewWriter returns a Writer writing to dir, compressing
+// pages with compression, one of Compressions
+func NewWriter(dir, compression string) (*Writer, error) {
+	if !supported(compression) {
+		return nil, fmt.Errorf("unknown compression %q, use one of %s", compression, strings.Join(Compressions, ", "))
+	}
+	codec, err := pq.CompressionCodecFromString(strings.ToUpper(compression))
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		dir:          dir,
+		compression:  codec,
+		RowGroupSize: DefaultRowGroupSize,
+		tables:       map[string]*table{},
+	}, nil
+}
+
+func supported(compression string) bool {
+	for _, c := range Compressions {
+		if c == compression {
+			return true
+		}
+	}
+	return false
+}
+
+// metadata returns the schema of the file of a table
+func metadata(columns []drugbank.Column) []string {
+	var md []string
+	for _, column := range columns {
+		md = append(md, fmt.Sprintf("name=%s, %s", strings.Replace(column.Name, "-", "_", -1), types[column.Kind]))
+	}
+	return md
+}
+
+// open creates the file of the named table
+func (w *Writer) open(name string, row interface{}) (*table, error) {
+	file, err := os.Create(filepath.Join(w.dir, name+".parquet"))
+	if err != nil {
+		return nil, err
+	}
+	w.files = append(w.files, file.Name())
+	t := &table{file: file, buffer: bufio.NewWriterSize(file, 64*1024), columns: drugbank.Columns(row)}
+	t.writer, err = writer.NewCSVWriterFromWriter(metadata(t.columns), t.buffer, 1)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	t.writer.CompressionType = w.compression
+	t.writer.RowGroupSize = w.RowGroupSize
+	return t, nil
+}
+
+// Write appends row to the named table
+func (w *Writer) Write(name string, row interface{}) error {
+	t, ok := w.tables[name]
+	if !ok {
+		var err error
+		if t, err = w.open(name, row); err != nil {
+			return err
+		}
+		w.tables[name] = t
+		w.order = append(w.order, name)
+	}
+	// records are buffered until their row group is full, so cannot be reused
+	record := make([]interface{}, len(t.columns))
+	for i, column := range t.columns {
+		v, err := value(column, row)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		record[i] = v
+	}
+	return t.writer.Write(record)
+}
+
+// value returns the value of a column as stored in the file
+func value(column drugbank.Column, row interface{}) (interface{}, error) {
+	switch column.Kind {
+	case drugbank.KindBool:
+		return column.Value(row), nil
+	case drugbank.KindInt:
+		return reflect.ValueOf(column.Value(row)).Int(), nil
+	case drugbank.KindFloat:
+		return reflect.ValueOf(column.Value(row)).Float(), nil
+	case drugbank.KindDate:
+		date := column.Value(row).(drugbank.Date)
+		if date.IsZero() {
+			return nil, nil
+		}
+		return date.UnixNano() / int64(time.Millisecond), nil
+	default:
+		return column.String(row), nil
+	}
+}
+
+// Files returns the paths of the files created, in order
+func (w *Writer) Files() []string {
+	return w.files
+}
+
+// Close writes the footers of the files and closes them
+func (w *Writer) Close() error {
+	var firstErr error
+	for _, name := range w.order {
+		t := w.tables[name]
+		if err := t.writer.WriteStop(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %v", name, err)
+		}
+		if err := t.buffer.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := t.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	w.tables = map[string]*table{}
+	w.order = nil
+	return firstErr
+}
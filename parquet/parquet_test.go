@@ -0,0 +1,83 @@
+package parquet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+
+	drugbank "github.com/iz4vve/drugbank-dataset-parser"
+)
+
+func TestWriterRowGroups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w, err := NewWriter(dir, "snappy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.RowGroupSize = 4 * 1024
+
+	const drugs = 5000
+	approved := time.Date(2005, 6, 13, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < drugs; i++ {
+		drug := &drugbank.Drug{ID: fmt.Sprintf("DB%05d", i), Name: "Lepirudin"}
+		drug.Patents = []drugbank.Patent{{Number: fmt.Sprint(i), Country: "United States", Pediatric: i%2 == 0}}
+		if i%3 == 0 {
+			drug.Patents[0].Approved = drugbank.Date{Time: approved}
+		}
+		for _, row := range drugbank.Rows(drug) {
+			if err := w.Write(row.Table, row.Value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := local.NewLocalFileReader(filepath.Join(dir, "patents.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	r, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.ReadStop()
+	if rows := r.GetNumRows(); rows != drugs {
+		t.Fatalf("%d rows, want %d", rows, drugs)
+	}
+	if groups := len(r.Footer.RowGroups); groups < 2 {
+		t.Errorf("%d row groups, want the rows split by RowGroupSize", groups)
+	}
+
+	// drugbank-id number country approved expiration pediatric
+	values, _, _, err := r.ReadColumnByIndex(3, drugs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != drugs {
+		t.Fatalf("%d dates read, want %d", len(values), drugs)
+	}
+	for i, value := range values {
+		if i%3 != 0 {
+			if value != nil {
+				t.Errorf("patent %d approved on %v, want null", i, value)
+			}
+			continue
+		}
+		if value != approved.UnixNano()/int64(time.Millisecond) {
+			t.Errorf("patent %d approved on %v, want %s", i, value, approved)
+		}
+	}
+}
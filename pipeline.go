@@ -0,0 +1,376 @@
+package drugbank
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Record is a drug decoded by a Pipeline, together with
+// the table rows it fans out to. Rows with a Key are only
+// included in the record of the first drug they appear in.
+type Record struct {
+	Index int   // position of the drug in the document
+	End   int64 // offset of the end of the drug in the document, in bytes
+	Drug  *Drug
+	Rows  []Row
+	// Errors are the recoverable errors of the fields of Drug left
+	// zero because their value was invalid, such as the dates of
+	// patents and products (see Date). The rest of the drug is kept.
+	Errors []*DecodeError
+}
+
+// Pipeline decodes drugs concurrently: a single goroutine tokenizes
+// the document and hands every top-level drug element to a pool of
+// workers, which decode it and fan it out to the output tables.
+// Records are delivered in document order regardless of the number of workers.
+type Pipeline struct {
+	// Reject, if set, is called in document order with the drugs that
+	// could be read but not decoded, together with their xml,
+	// instead of stopping the pipeline. Run stops if Reject returns an error.
+	Reject func(err *DecodeError, element []byte) error
+	// Coverage, if set, counts the element and attribute paths of
+	// every drug read, whether it could be decoded or not.
+	Coverage *Coverage
+
+	decoder *xml.Decoder
+	workers int
+	path    []string // elements currently open in the decoder
+	release Release
+}
+
+// Release identifies the DrugBank release a document was exported
+// from, as given by the attributes of its root element
+type Release struct {
+	Version    string `json:"version"`
+	ExportedOn string `json:"exported-on"`
+}
+
+// job is the token stream of a single drug element
+type job struct {
+	index  int
+	offset int64
+	end    int64
+	tokens []xml.Token
+}
+
+// result is the outcome of decoding a job
+type result struct {
+	record  *Record
+	err     *DecodeError
+	element []byte // xml of the drug, set for recoverable errors
+}
+
+// NewPipeline returns a Pipeline decoding the drugs contained in r
+// with the given number of workers. If workers is less than 1
+// the number of CPUs is used.
+func NewPipeline(r io.Reader, workers int) *Pipeline {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	return &Pipeline{
+		decoder: xml.NewDecoder(r),
+		workers: workers,
+	}
+}
+
+// Run decodes the whole document, calling fn for every record in
+// document order from the calling goroutine. It stops at the first
+// error returned by the decoder or by fn.
+func (p *Pipeline) Run(fn func(*Record) error) error {
+	var (
+		jobs    = make(chan job, p.workers)
+		results = make(chan result, p.workers)
+		// slots bounds the number of drugs in flight, so that a slow
+		// drug cannot make the reordering buffer grow without limit
+		slots = make(chan struct{}, 4*p.workers)
+		done  = make(chan struct{})
+		wg    sync.WaitGroup
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for index := 0; ; index++ {
+			offset, end, tokens, err := p.nextDrug()
+			if err == io.EOF {
+				return
+			}
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				decodeErr := &DecodeError{
+					Index:  index,
+					Offset: offset,
+					Path:   strings.Join(p.path, "/"),
+					Err:    err,
+				}
+				select {
+				case results <- result{&Record{Index: index}, decodeErr, nil}:
+				case <-done:
+				}
+				return
+			}
+			select {
+			case jobs <- job{index, offset, end, tokens}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for j := range jobs {
+				select {
+				case results <- p.decode(j):
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		workers.Wait()
+		close(results)
+	}()
+
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	seen := map[string]map[string]bool{} // keys of the rows emitted so far, by table
+	pending := map[int]result{}
+	next := 0
+	for res := range results {
+		pending[res.record.Index] = res
+		for {
+			res, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-slots
+			if res.err != nil {
+				if !res.err.Recoverable || p.Reject == nil {
+					return res.err
+				}
+				if err := p.Reject(res.err, res.element); err != nil {
+					return err
+				}
+				continue
+			}
+			res.record.Rows = unique(res.record.Rows, seen)
+			if err := fn(res.record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Release returns the release of the document, once Run returned
+func (p *Pipeline) Release() Release {
+	return p.release
+}
+
+func newRelease(root xml.StartElement) Release {
+	var release Release
+	for _, attr := range root.Attr {
+		switch attr.Name.Local {
+		case "version":
+			release.Version = attr.Value
+		case "exported-on":
+			release.ExportedOn = attr.Value
+		}
+	}
+	return release
+}
+
+// unique removes the keyed rows that have already been seen
+func unique(rows []Row, seen map[string]map[string]bool) []Row {
+	kept := rows[:0]
+	for _, row := range rows {
+		if row.Key != "" {
+			if seen[row.Table] == nil {
+				seen[row.Table] = map[string]bool{}
+			}
+			if seen[row.Table][row.Key] {
+				continue
+			}
+			seen[row.Table][row.Key] = true
+		}
+		kept = append(kept, row)
+	}
+	return kept
+}
+
+// nextDrug reads the tokens of the next top-level drug element,
+// including its start and end elements, and the offsets at which it starts
+// and ends. It returns io.EOF at the end of the document.
+func (p *Pipeline) nextDrug() (int64, int64, []xml.Token, error) {
+	for {
+		offset := p.decoder.InputOffset()
+		token, err := p.nextToken()
+		if err != nil {
+			return offset, offset, nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if ok && len(p.path) == 1 && start.Name.Local == "drugbank" {
+			p.release = newRelease(start)
+		}
+		if !ok || start.Name.Local != "drug" {
+			continue
+		}
+		tokens := []xml.Token{start.Copy()}
+		for depth := 1; depth > 0; {
+			token, err := p.nextToken()
+			if err == io.EOF {
+				return offset, offset, nil, io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return offset, offset, nil, err
+			}
+			switch token.(type) {
+			case xml.StartElement:
+				depth++
+			case xml.EndElement:
+				depth--
+			}
+			tokens = append(tokens, xml.CopyToken(token))
+		}
+		return offset, p.decoder.InputOffset(), tokens, nil
+	}
+}
+
+// nextToken reads the next token, keeping track of the open elements
+func (p *Pipeline) nextToken() (xml.Token, error) {
+	token, err := p.decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := token.(type) {
+	case xml.StartElement:
+		p.path = append(p.path, t.Name.Local)
+	case xml.EndElement:
+		p.path = p.path[:len(p.path)-1]
+	}
+	return token, nil
+}
+
+// decode decodes the drug in j and fans it out to its rows
+func (p *Pipeline) decode(j job) result {
+	if p.Coverage != nil {
+		p.Coverage.Add(j.tokens)
+	}
+	d := &Drug{}
+	tokens := &tokenReader{tokens: j.tokens}
+	if err := xml.NewTokenDecoder(tokens).Decode(d); err != nil {
+		d.resolveIDs()
+		if d.ID == "" {
+			// failed before the ids were read, e.g. on an attribute
+			d.IDs = drugIDs(j.tokens)
+			d.resolveIDs()
+		}
+		decodeErr := &DecodeError{
+			Index:       j.index,
+			Offset:      j.offset,
+			Path:        tokens.path(),
+			ID:          d.ID,
+			Err:         err,
+			Recoverable: true,
+		}
+		var element []byte
+		if p.Reject != nil {
+			element = encodeTokens(j.tokens)
+		}
+		return result{&Record{Index: j.index, End: j.end}, decodeErr, element}
+	}
+	record := &Record{Index: j.index, End: j.end, Drug: d, Rows: Rows(d), Errors: d.invalidDates()}
+	for _, err := range record.Errors {
+		err.Index, err.Offset = j.index, j.offset
+	}
+	return result{record, nil, nil}
+}
+
+// drugIDs decodes only the ids of the drug in tokens
+func drugIDs(tokens []xml.Token) []DrugbankID {
+	var ids struct {
+		IDs []DrugbankID `xml:"drugbank-id"`
+	}
+	xml.NewTokenDecoder(&tokenReader{tokens: tokens}).Decode(&ids)
+	return ids.IDs
+}
+
+// tokenReader replays a slice of tokens as an xml.TokenReader,
+// keeping track of the elements it went through
+type tokenReader struct {
+	tokens []xml.Token
+	open   []string // elements currently open
+	closed string   // last element closed
+}
+
+func (r *tokenReader) Token() (xml.Token, error) {
+	if len(r.tokens) == 0 {
+		return nil, io.EOF
+	}
+	token := r.tokens[0]
+	r.tokens = r.tokens[1:]
+	r.closed = ""
+	switch t := token.(type) {
+	case xml.StartElement:
+		r.open = append(r.open, t.Name.Local)
+	case xml.EndElement:
+		r.closed = t.Name.Local
+		r.open = r.open[:len(r.open)-1]
+	}
+	return token, nil
+}
+
+// path returns the path of the element being decoded. Values are
+// converted when their element ends, so an element that has just been
+// closed is still part of the path.
+func (r *tokenReader) path() string {
+	path := append([]string{"drugbank"}, r.open...)
+	if r.closed != "" {
+		path = append(path, r.closed)
+	}
+	return strings.Join(path, "/")
+}
+
+// encodeTokens serializes the tokens of an element back to xml
+func encodeTokens(tokens []xml.Token) []byte {
+	var buffer bytes.Buffer
+	encoder := xml.NewEncoder(&buffer)
+	for _, token := range tokens {
+		// names are already resolved to the document namespace,
+		// drop it to avoid an xmlns attribute on every element
+		switch t := token.(type) {
+		case xml.StartElement:
+			t.Name.Space = ""
+			token = t
+		case xml.EndElement:
+			t.Name.Space = ""
+			token = t
+		}
+		if err := encoder.EncodeToken(token); err != nil {
+			break
+		}
+	}
+	encoder.Flush()
+	return buffer.Bytes()
+}
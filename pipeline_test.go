@@ -0,0 +1,152 @@
+package drugbank
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// brokenDrug is a drug that can be read but not decoded
+const brokenDrug = `<drug type="small molecule" created="2005-06-13" updated="2019-01-01">
+  <drugbank-id primary="true">DB99999</drugbank-id>
+  <name>Broken</name>
+  <prices><price><description>Broken</description><cost currency="USD">not a number</cost></price></prices>
+</drug>
+`
+
+// readFixture returns the test dataset with its drugs repeated copies
+// times, followed by extra drugs
+func readFixture(t *testing.T, copies int, extra ...string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "drugbank.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	start := strings.Index(text, "<drug ")
+	end := strings.LastIndex(text, "</drugbank>")
+	var document strings.Builder
+	document.WriteString(text[:start])
+	for i := 0; i < copies; i++ {
+		document.WriteString(text[start:end])
+		// the broken drug in the middle of the copies
+		if i == copies/2 {
+			for _, drug := range extra {
+				document.WriteString(drug)
+			}
+		}
+	}
+	document.WriteString(text[end:])
+	return []byte(document.String())
+}
+
+// run parses document with the given number of workers to csv files in a
+// temporary directory, returning it along with the indexes of the drugs
+// rejected and the paths counted
+func run(t *testing.T, document []byte, workers int) (string, []int, []PathCount) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "pipeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := NewCSVWriter(dir)
+	pipeline := NewPipeline(bytes.NewReader(document), workers)
+	pipeline.Coverage = NewCoverage()
+	var rejected []int
+	pipeline.Reject = func(err *DecodeError, element []byte) error {
+		rejected = append(rejected, err.Index)
+		return nil
+	}
+	next := 0
+	err = pipeline.Run(func(record *Record) error {
+		if record.Index < next {
+			t.Errorf("drug %d delivered after drug %d", record.Index, next-1)
+		}
+		next = record.Index + 1
+		for _, row := range record.Rows {
+			if err := writer.Write(row.Table, row.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return dir, rejected, pipeline.Coverage.Paths()
+}
+
+func TestPipelineWorkers(t *testing.T) {
+	document := readFixture(t, 50, brokenDrug)
+	sequential, rejected, paths := run(t, document, 1)
+	defer os.RemoveAll(sequential)
+	// the broken drug follows 26 copies of the 3 drugs of the fixture
+	if len(rejected) != 1 || rejected[0] != 78 {
+		t.Fatalf("rejected drugs %v, want [78]", rejected)
+	}
+	files, err := ioutil.ReadDir(sequential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) < 20 {
+		t.Fatalf("only %d tables written", len(files))
+	}
+
+	for _, workers := range []int{2, 8} {
+		concurrent, concurrentRejected, concurrentPaths := run(t, document, workers)
+		defer os.RemoveAll(concurrent)
+		if len(concurrentRejected) != 1 || concurrentRejected[0] != rejected[0] {
+			t.Errorf("%d workers: rejected drugs %v, want %v", workers, concurrentRejected, rejected)
+		}
+		if len(concurrentPaths) != len(paths) {
+			t.Errorf("%d workers: %d paths counted, want %d", workers, len(concurrentPaths), len(paths))
+		}
+		for i := range paths {
+			if i < len(concurrentPaths) && concurrentPaths[i] != paths[i] {
+				t.Errorf("%d workers: path %+v, want %+v", workers, concurrentPaths[i], paths[i])
+			}
+		}
+		concurrentFiles, err := ioutil.ReadDir(concurrent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(concurrentFiles) != len(files) {
+			t.Errorf("%d workers: %d tables written, want %d", workers, len(concurrentFiles), len(files))
+		}
+		for _, file := range files {
+			want, err := ioutil.ReadFile(filepath.Join(sequential, file.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(filepath.Join(concurrent, file.Name()))
+			if err != nil {
+				t.Errorf("%d workers: %v", workers, err)
+				continue
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%d workers: %s differs from the output of a single worker", workers, file.Name())
+			}
+		}
+	}
+}
+
+func TestPipelineRelease(t *testing.T) {
+	pipeline := NewPipeline(bytes.NewReader(readFixture(t, 1)), 2)
+	drugs := 0
+	if err := pipeline.Run(func(*Record) error { drugs++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if drugs != 3 {
+		t.Errorf("%d drugs, want 3", drugs)
+	}
+	want := Release{Version: "5.1", ExportedOn: "2018-12-20"}
+	if pipeline.Release() != want {
+		t.Errorf("release %+v, want %+v", pipeline.Release(), want)
+	}
+}
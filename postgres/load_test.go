@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	drugbank "github.com/iz4vve/drugbank-dataset-parser"
+)
+
+// dsnVariable names the environment variable holding the connection
+// string of the database the integration tests run against.
+// Its drugbank tables are dropped and created again.
+const dsnVariable = "DRUGBANK_TEST_POSTGRES"
+
+// writeTables parses the test dataset to tab separated files in a
+// temporary directory, returning it with the number of rows of every table
+func writeTables(t *testing.T) (string, map[string]int64) {
+	t.Helper()
+	input, err := os.Open(filepath.Join("..", "testdata", "drugbank.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer input.Close()
+	dir, err := ioutil.TempDir("", "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := drugbank.NewTSVWriter(dir)
+	rows := map[string]int64{}
+	err = drugbank.NewPipeline(input, 2).Run(func(record *drugbank.Record) error {
+		for _, row := range record.Rows {
+			rows[row.Table]++
+			if err := writer.Write(row.Table, row.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return dir, rows
+}
+
+// query returns the first column of the first row of the result of sql
+func query(t *testing.T, conn *pgconn.PgConn, sql string) string {
+	t.Helper()
+	results, err := conn.Exec(context.Background(), sql).ReadAll()
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+	if len(results) == 0 || len(results[0].Rows) == 0 {
+		t.Fatalf("%s: no rows", sql)
+	}
+	return string(results[0].Rows[0][0])
+}
+
+func TestLoad(t *testing.T) {
+	dsn := os.Getenv(dsnVariable)
+	if dsn == "" {
+		t.Skipf("set %s to the connection string of a test database", dsnVariable)
+	}
+	ctx := context.Background()
+	dir, want := writeTables(t)
+	defer os.RemoveAll(dir)
+
+	// loading twice checks that the tables are dropped and created again
+	for load := 1; load <= 2; load++ {
+		rows, err := Load(ctx, dsn, dir, drugbank.Tables)
+		if err != nil {
+			t.Fatalf("load %d: %v", load, err)
+		}
+		conn, err := pgconn.Connect(ctx, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close(ctx)
+
+		foreignKeys := 0
+		for _, table := range loaded(drugbank.Tables) {
+			if rows[table.Name] != want[table.Name] {
+				t.Errorf("load %d: %d rows copied into %s, want %d", load, rows[table.Name], table.Name, want[table.Name])
+			}
+			count := query(t, conn, "SELECT count(*) FROM "+sqlName(table.Name))
+			if count != strconv.FormatInt(want[table.Name], 10) {
+				t.Errorf("load %d: %s rows in %s, want %d", load, count, table.Name, want[table.Name])
+			}
+			if _, ok := table.Column("drugbank-id"); ok && table.Name != "drugs" {
+				foreignKeys++
+			}
+		}
+
+		// foreign keys are created, but not checked against the rows copied
+		notValid := query(t, conn, `SELECT count(*) FROM pg_constraint
+			WHERE contype = 'f' AND NOT convalidated AND confrelid = 'drugs'::regclass`)
+		if notValid != strconv.Itoa(foreignKeys) {
+			t.Errorf("load %d: %s foreign keys NOT VALID, want %d", load, notValid, foreignKeys)
+		}
+		indexes := query(t, conn, `SELECT count(*) FROM pg_indexes WHERE indexname = 'drug_targets_drugbank_id'`)
+		if indexes != "1" {
+			t.Errorf("load %d: index drug_targets_drugbank_id missing", load)
+		}
+
+		// dates are copied from the text format
+		updated := query(t, conn, `SELECT record_update::text FROM drugs WHERE drugbank_id = 'DB00001'`)
+		if updated != "2018-12-03" {
+			t.Errorf("load %d: DB00001 updated on %q, want 2018-12-03", load, updated)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package postgres loads the drugbank output tables into PostgreSQL.
+// The tables are written as tab separated files in the text format of
+// COPY (see drugbank.NewTSVWriter), along with schema.sql creating the
+// tables, constraints.sql creating the indexes and foreign keys once
+// they are filled, and load.sql, a psql script running all three from
+// the directory of the files:
+//
+//	cd <outputdir> && psql -d drugbank -f load.sql
+//
+// Load does the same over a connection, streaming the files with
+// COPY FROM STDIN.
+package postgres
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	drugbank "github.com/iz4vve/drugbank-dataset-parser"
+)
+
+const header = "-- Generated by drugbank export postgres, do not edit.\n"
+
+// sql types of the column kinds
+var types = map[drugbank.Kind]string{
+	drugbank.KindString: "TEXT",
+	drugbank.KindBool:   "BOOLEAN",
+	drugbank.KindInt:    "BIGINT",
+	drugbank.KindFloat:  "NUMERIC",
+	drugbank.KindDate:   "DATE",
+}
+
+// loaded returns the tables stored in PostgreSQL, which leaves out sequences
+func loaded(tables []drugbank.Table) []drugbank.Table {
+	var loaded []drugbank.Table
+	for _, table := range tables {
+		if !table.Sequence {
+			loaded = append(loaded, table)
+		}
+	}
+	return loaded
+}
+
+// sqlName returns the sql name of a table or column
+func sqlName(name string) string {
+	return strings.Replace(name, "-", "_", -1)
+}
+
+// columnList returns the names of the columns of a table, comma separated
+func columnList(table drugbank.Table) string {
+	var names []string
+	for _, column := range table.Columns() {
+		names = append(names, sqlName(column.Name))
+	}
+	return strings.Join(names, ", ")
+}
+
+// WriteSchema writes the statements creating the tables, dropping
+// them first. Tables with a key use it as their primary key, other
+// tables get an id column.
+func WriteSchema(w io.Writer, tables []drugbank.Table) error {
+	buffer := bufio.NewWriter(w)
+	fmt.Fprint(buffer, header)
+	for _, table := range loaded(tables) {
+		var definitions []string
+		if len(table.Key) == 0 {
+			definitions = append(definitions, "id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY")
+		}
+		for _, column := range table.Columns() {
+			definitions = append(definitions, sqlName(column.Name)+" "+types[column.Kind])
+		}
+		if len(table.Key) > 0 {
+			var key []string
+			for _, name := range table.Key {
+				key = append(key, sqlName(name))
+			}
+			definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(key, ", ")))
+		}
+		fmt.Fprintf(buffer, "\nDROP TABLE IF EXISTS %s CASCADE;\n", sqlName(table.Name))
+		fmt.Fprintf(buffer, "CREATE TABLE %s (\n\t%s\n);\n", sqlName(table.Name), strings.Join(definitions, ",\n\t"))
+	}
+	return buffer.Flush()
+}
+
+// WriteConstraints writes the statements creating an index on each
+// of the columns tables are joined on, i.e. the ones holding IDs, and
+// a foreign key to drugs on every drugbank-id column. Drugs may refer
+// to drugs missing from the dataset (e.g. in pathways), so the foreign
+// keys are NOT VALID: they only apply to rows inserted later.
+func WriteConstraints(w io.Writer, tables []drugbank.Table) error {
+	buffer := bufio.NewWriter(w)
+	fmt.Fprint(buffer, header)
+	for _, table := range loaded(tables) {
+		name := sqlName(table.Name)
+		for _, column := range table.Columns() {
+			if !joinColumn(column.Name) || (len(table.Key) > 0 && table.Key[0] == column.Name) {
+				continue
+			}
+			fmt.Fprintf(buffer, "CREATE INDEX %s_%s ON %s (%s);\n",
+				name, sqlName(column.Name), name, sqlName(column.Name))
+		}
+		if _, ok := table.Column("drugbank-id"); ok && table.Name != "drugs" {
+			fmt.Fprintf(buffer, "ALTER TABLE %s ADD FOREIGN KEY (drugbank_id) REFERENCES drugs (drugbank_id) NOT VALID;\n", name)
+		}
+	}
+	fmt.Fprintln(buffer, "ANALYZE;")
+	return buffer.Flush()
+}
+
+func joinColumn(name string) bool {
+	return strings.HasSuffix(name, "-id") || name == "atc-code"
+}
+
+// copyStatement returns the statement copying a table from the client
+func copyStatement(table drugbank.Table) string {
+	return fmt.Sprintf("COPY %s (%s) FROM STDIN", sqlName(table.Name), columnList(table))
+}
+
+// file returns the path of the tab separated file of a table in dir
+func file(dir string, table drugbank.Table) string {
+	return filepath.Join(dir, table.Name+".tsv")
+}
+
+// WriteLoadScript writes a psql script creating the tables, copying
+// the files of dir into them and creating the constraints. Tables
+// without a file, which had no rows, are left empty.
+func WriteLoadScript(w io.Writer, tables []drugbank.Table, dir string) error {
+	buffer := bufio.NewWriter(w)
+	fmt.Fprint(buffer, header)
+	fmt.Fprintln(buffer, `\set ON_ERROR_STOP on`)
+	fmt.Fprintln(buffer, "BEGIN;")
+	fmt.Fprintln(buffer, `\i schema.sql`)
+	for _, table := range loaded(tables) {
+		if _, err := os.Stat(file(dir, table)); os.IsNotExist(err) {
+			continue
+		}
+		fmt.Fprintf(buffer, "\\copy %s (%s) FROM '%s'\n", sqlName(table.Name), columnList(table), table.Name+".tsv")
+	}
+	fmt.Fprintln(buffer, `\i constraints.sql`)
+	fmt.Fprintln(buffer, "COMMIT;")
+	return buffer.Flush()
+}
@@ -0,0 +1,391 @@
+package drugbank
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RDFSyntax is the serialization of the triples written by an RDF writer
+type RDFSyntax int
+
+const (
+	// Turtle writes the triples with prefixed names where possible
+	Turtle RDFSyntax = iota
+	// NTriples writes one triple per line with full IRIs
+	NTriples
+)
+
+const (
+	identifiersOrg = "https://identifiers.org/"
+	// RDFVocabulary is the namespace of the classes and properties
+	// that have no equivalent in a standard vocabulary
+	RDFVocabulary = "https://github.com/iz4vve/drugbank-dataset-parser/vocabulary#"
+	// RDFResources is the namespace of the resources that have no
+	// IRI of their own, such as interactions between two drugs
+	RDFResources = "https://github.com/iz4vve/drugbank-dataset-parser/resource/"
+)
+
+// rdfPrefixes are the namespaces abbreviated in Turtle
+var rdfPrefixes = []struct{ prefix, namespace string }{
+	{"rdf", "http://www.w3.org/1999/02/22-rdf-syntax-ns#"},
+	{"rdfs", "http://www.w3.org/2000/01/rdf-schema#"},
+	{"xsd", "http://www.w3.org/2001/XMLSchema#"},
+	{"dcterms", "http://purl.org/dc/terms/"},
+	{"skos", "http://www.w3.org/2004/02/skos/core#"},
+	{"schema", "http://schema.org/"},
+	{"dbv", RDFVocabulary},
+	{"dbr", RDFResources},
+	{"drugbank", identifiersOrg + "drugbank:"},
+	{"drugbank.target", identifiersOrg + "drugbank.target:"},
+	{"uniprot", identifiersOrg + "uniprot:"},
+	{"mesh", identifiersOrg + "mesh:"},
+	{"atc", identifiersOrg + "atc:"},
+}
+
+// rdfLocalName matches the local names written as prefixed names in Turtle
+var rdfLocalName = regexp.MustCompile(`^[A-Za-z0-9_]([A-Za-z0-9_.-]*[A-Za-z0-9_-])?$`)
+
+// externalResource is the identifiers.org prefix of a resource listed in
+// external-identifiers. Identifiers of resources describing the same
+// substance are exact matches of the drug, others are only related.
+type externalResource struct {
+	prefix string
+	exact  bool
+}
+
+// externalResources maps the resources of external identifiers to
+// identifiers.org. Identifiers of other resources are kept as literals.
+var externalResources = map[string]externalResource{
+	"BindingDB":                    {"bindingdb", true},
+	"ChEBI":                        {"CHEBI", true},
+	"ChEMBL":                       {"chembl.compound", true},
+	"ChemSpider":                   {"chemspider", true},
+	"Drugs Product Database (DPD)": {"cdpd", false},
+	"GenBank":                      {"insdc", false},
+	"Guide to Pharmacology":        {"iuphar.ligand", true},
+	"IUPHAR":                       {"iuphar.ligand", true},
+	"KEGG Compound":                {"kegg.compound", true},
+	"KEGG Drug":                    {"kegg.drug", true},
+	"PDB":                          {"pdb", false},
+	"PharmGKB":                     {"pharmgkb.drug", true},
+	"PubChem Compound":             {"pubchem.compound", true},
+	"PubChem Substance":            {"pubchem.substance", false},
+	"RxCUI":                        {"rxnorm", true},
+	"Therapeutic Targets Database": {"ttd.drug", true},
+	"UniProtKB":                    {"uniprot", false},
+	"Wikipedia":                    {"wikipedia.en", false},
+	"ZINC":                         {"zinc", true},
+}
+
+// bioEntityTables maps the tables linking drugs to bio entities
+// to the property linking them and the class of the entities
+var bioEntityTables = map[string][2]string{
+	"drug_targets":      {"target", "Target"},
+	"drug_enzymes":      {"enzyme", "Enzyme"},
+	"drug_carriers":     {"carrier", "Carrier"},
+	"drug_transporters": {"transporter", "Transporter"},
+}
+
+// identifier returns the identifiers.org IRI of an identifier. The
+// prefix is stripped from identifiers that embed it, e.g. CHEBI:4911.
+func identifier(prefix, id string) string {
+	return identifiersOrg + prefix + ":" + url.PathEscape(strings.TrimPrefix(id, prefix+":"))
+}
+
+// rdfWriter writes drugs and their relations as RDF triples:
+// drugs, their interactions, targets, enzymes, carriers and
+// transporters, categories, ATC codes and external identifiers.
+// Rows of other tables are dropped.
+type rdfWriter struct {
+	file   *os.File
+	buffer *bufio.Writer
+	syntax RDFSyntax
+	seen   map[string]bool // resources described already
+}
+
+// NewRDFWriter returns a TableWriter writing the triples describing
+// drugs to a file at path, in syntax. Entities are named by their
+// identifiers.org IRIs and described with Dublin Core, SKOS and
+// schema.org where possible.
+func NewRDFWriter(path string, syntax RDFSyntax) (TableWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &rdfWriter{file, bufio.NewWriterSize(file, 64*1024), syntax, map[string]bool{}}
+	if syntax == Turtle {
+		for _, p := range rdfPrefixes {
+			fmt.Fprintf(w.buffer, "@prefix %s: <%s> .\n", p.prefix, p.namespace)
+		}
+		fmt.Fprintln(w.buffer)
+	}
+	return w, nil
+}
+
+// iri returns the term naming a resource
+func (w *rdfWriter) iri(iri string) string {
+	if w.syntax == Turtle {
+		for _, p := range rdfPrefixes {
+			if local := strings.TrimPrefix(iri, p.namespace); local != iri && rdfLocalName.MatchString(local) {
+				return p.prefix + ":" + local
+			}
+		}
+	}
+	return "<" + iri + ">"
+}
+
+var rdfEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
+// literal returns the term of a string, typed if datatype is not empty
+func (w *rdfWriter) literal(value, datatype string) string {
+	term := `"` + rdfEscaper.Replace(value) + `"`
+	if datatype != "" {
+		term += "^^" + w.iri(datatype)
+	}
+	return term
+}
+
+// triple writes a triple, given the IRIs of its subject and
+// predicate and the term of its object
+func (w *rdfWriter) triple(subject, predicate, object string) error {
+	_, err := fmt.Fprintf(w.buffer, "%s %s %s .\n", w.iri(subject), w.iri(predicate), object)
+	return err
+}
+
+// property writes a triple with a string literal as object, if not empty
+func (w *rdfWriter) property(subject, predicate, value string) error {
+	if value == "" {
+		return nil
+	}
+	return w.triple(subject, predicate, w.literal(value, ""))
+}
+
+// link writes a triple with an IRI as object
+func (w *rdfWriter) link(subject, predicate, object string) error {
+	return w.triple(subject, predicate, w.iri(object))
+}
+
+// describe calls write the first time resource is seen
+func (w *rdfWriter) describe(resource string, write func() error) error {
+	if w.seen[resource] {
+		return nil
+	}
+	w.seen[resource] = true
+	return write()
+}
+
+const (
+	rdfType         = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+	rdfsLabel       = "http://www.w3.org/2000/01/rdf-schema#label"
+	rdfsSeeAlso     = "http://www.w3.org/2000/01/rdf-schema#seeAlso"
+	xsdDate         = "http://www.w3.org/2001/XMLSchema#date"
+	dctermsID       = "http://purl.org/dc/terms/identifier"
+	dctermsDesc     = "http://purl.org/dc/terms/description"
+	dctermsCreated  = "http://purl.org/dc/terms/created"
+	dctermsModified = "http://purl.org/dc/terms/modified"
+	dctermsSubject  = "http://purl.org/dc/terms/subject"
+	skosExactMatch  = "http://www.w3.org/2004/02/skos/core#exactMatch"
+	skosBroader     = "http://www.w3.org/2004/02/skos/core#broaderTransitive"
+	schemaDrug      = "http://schema.org/Drug"
+)
+
+func (w *rdfWriter) Write(table string, row interface{}) error {
+	if link, ok := bioEntityTables[table]; ok {
+		var enzyme drugEnzymeRow
+		switch r := row.(type) {
+		case bioEntityRow:
+			enzyme.bioEntityRow = r
+		case drugEnzymeRow:
+			enzyme = r
+		}
+		return w.bioEntity(link[0], link[1], enzyme)
+	}
+	switch r := row.(type) {
+	case *Drug:
+		return w.drug(r)
+	case drugInteractionRow:
+		return w.interaction(r)
+	case bioEntityPolypeptideRow:
+		return w.link(identifier("drugbank.target", r.BioEntityID), RDFVocabulary+"polypeptide", identifier("uniprot", r.UNIPROTID))
+	case categoryRow:
+		return w.category(r)
+	case atcCodeRow:
+		return w.link(identifier("drugbank", r.DrugID), RDFVocabulary+"atcCode", identifier("atc", r.ATCCode))
+	case atcLevelRow:
+		level := identifier("atc", r.ATCLevelCode)
+		if err := w.link(identifier("atc", r.ATCCode), skosBroader, level); err != nil {
+			return err
+		}
+		return w.describe(level, func() error {
+			return w.property(level, rdfsLabel, r.Description)
+		})
+	case externalIdentifierRow:
+		return w.externalIdentifier(r)
+	}
+	return nil
+}
+
+func (w *rdfWriter) drug(d *Drug) error {
+	drug := identifier("drugbank", d.ID)
+	if err := w.link(drug, rdfType, schemaDrug); err != nil {
+		return err
+	}
+	for _, date := range []struct {
+		predicate string
+		value     Date
+	}{
+		{dctermsCreated, d.DrugRecordCreatedOn},
+		{dctermsModified, d.DrugRecordUpdatedOn},
+	} {
+		if date.value.IsZero() {
+			continue
+		}
+		if err := w.triple(drug, date.predicate, w.literal(date.value.String(), xsdDate)); err != nil {
+			return err
+		}
+	}
+	for _, p := range []struct{ predicate, value string }{
+		{dctermsID, d.ID},
+		{rdfsLabel, d.Name},
+		{dctermsDesc, d.Description},
+		{RDFVocabulary + "drugType", d.DrugType},
+		{RDFVocabulary + "casNumber", d.CAS},
+		{RDFVocabulary + "unii", d.UNII},
+		{RDFVocabulary + "state", d.State},
+		{RDFVocabulary + "indication", d.Indication},
+		{RDFVocabulary + "mechanismOfAction", d.MechanismOfAction},
+	} {
+		if err := w.property(drug, p.predicate, p.value); err != nil {
+			return err
+		}
+	}
+	if d.CAS != "" {
+		return w.link(drug, skosExactMatch, identifier("cas", d.CAS))
+	}
+	return nil
+}
+
+// interaction links two interacting drugs, and describes their
+// interaction once, as it is listed by both drugs
+func (w *rdfWriter) interaction(r drugInteractionRow) error {
+	drug, reagent := identifier("drugbank", r.DrugID), identifier("drugbank", r.ID)
+	if err := w.link(drug, RDFVocabulary+"interactsWith", reagent); err != nil {
+		return err
+	}
+	ids := []string{r.DrugID, r.ID}
+	sort.Strings(ids)
+	interaction := RDFResources + "interaction-" + url.PathEscape(ids[0]) + "-" + url.PathEscape(ids[1])
+	return w.describe(interaction, func() error {
+		if err := w.link(interaction, rdfType, RDFVocabulary+"DrugInteraction"); err != nil {
+			return err
+		}
+		if err := w.link(interaction, RDFVocabulary+"interactant", drug); err != nil {
+			return err
+		}
+		if err := w.link(interaction, RDFVocabulary+"interactant", reagent); err != nil {
+			return err
+		}
+		return w.property(interaction, dctermsDesc, r.Description)
+	})
+}
+
+// bioEntity links a drug to a target, enzyme, carrier or transporter.
+// How the drug acts on it is described by a resource of its own.
+func (w *rdfWriter) bioEntity(property, class string, r drugEnzymeRow) error {
+	drug, entity := identifier("drugbank", r.DrugID), identifier("drugbank.target", r.BioEntityID)
+	if err := w.link(drug, RDFVocabulary+property, entity); err != nil {
+		return err
+	}
+	if err := w.describe(entity, func() error {
+		if err := w.link(entity, rdfType, RDFVocabulary+class); err != nil {
+			return err
+		}
+		if err := w.property(entity, rdfsLabel, r.Name); err != nil {
+			return err
+		}
+		return w.property(entity, RDFVocabulary+"organism", r.Organism)
+	}); err != nil {
+		return err
+	}
+
+	relation := RDFResources + property + "-" + url.PathEscape(r.DrugID) + "-" + url.PathEscape(r.BioEntityID)
+	if err := w.link(relation, rdfType, RDFVocabulary+"BioEntityRelation"); err != nil {
+		return err
+	}
+	if err := w.link(relation, RDFVocabulary+"drug", drug); err != nil {
+		return err
+	}
+	if err := w.link(relation, RDFVocabulary+"bioEntity", entity); err != nil {
+		return err
+	}
+	if r.Actions != "" {
+		for _, action := range strings.Split(r.Actions, "|") {
+			if err := w.property(relation, RDFVocabulary+"action", action); err != nil {
+				return err
+			}
+		}
+	}
+	for _, p := range []struct{ predicate, value string }{
+		{RDFVocabulary + "knownAction", r.KnownAction},
+		{RDFVocabulary + "position", r.Position},
+		{RDFVocabulary + "inhibitionStrength", r.InhibitionStrength},
+		{RDFVocabulary + "inductionStrength", r.InductionStrength},
+	} {
+		if err := w.property(relation, p.predicate, p.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// category links a drug to its MeSH category, or to the
+// name of the category if it has no MeSH ID
+func (w *rdfWriter) category(r categoryRow) error {
+	drug := identifier("drugbank", r.DrugID)
+	if r.MeshID == "" {
+		return w.property(drug, dctermsSubject, r.Category.Category)
+	}
+	mesh := identifier("mesh", r.MeshID)
+	if err := w.link(drug, dctermsSubject, mesh); err != nil {
+		return err
+	}
+	return w.describe(mesh, func() error {
+		return w.property(mesh, rdfsLabel, r.Category.Category)
+	})
+}
+
+// externalIdentifier links a drug to its identifier in another
+// resource, or records the identifier if the resource is unknown
+func (w *rdfWriter) externalIdentifier(r externalIdentifierRow) error {
+	drug := identifier("drugbank", r.DrugID)
+	if r.Identifier == "" {
+		return nil
+	}
+	resource, ok := externalResources[r.Resource]
+	if !ok {
+		return w.property(drug, RDFVocabulary+"xref", r.Resource+":"+r.Identifier)
+	}
+	predicate := rdfsSeeAlso
+	if resource.exact {
+		predicate = skosExactMatch
+	}
+	return w.link(drug, predicate, identifier(resource.prefix, r.Identifier))
+}
+
+// Files returns the path of the file written
+func (w *rdfWriter) Files() []string {
+	return []string{w.file.Name()}
+}
+
+func (w *rdfWriter) Close() error {
+	if err := w.buffer.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
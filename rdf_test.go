@@ -0,0 +1,97 @@
+package drugbank
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRDFTerms(t *testing.T) {
+	turtle, ntriples := &rdfWriter{syntax: Turtle}, &rdfWriter{syntax: NTriples}
+	for _, test := range []struct {
+		iri, turtle string
+	}{
+		{identifier("drugbank", "DB00001"), "drugbank:DB00001"},
+		{identifier("drugbank.target", "BE0000048"), "drugbank.target:BE0000048"},
+		{identifier("atc", "B01AE02"), "atc:B01AE02"},
+		{RDFResources + "interaction-DB00001-DB00002", "dbr:interaction-DB00001-DB00002"},
+		{RDFVocabulary + "DrugInteraction", "dbv:DrugInteraction"},
+		// IRIs of namespaces without a prefix, and local names
+		// Turtle does not allow in prefixed names, are written in full
+		{identifier("CHEBI", "CHEBI:4911"), "<https://identifiers.org/CHEBI:4911>"},
+		{identifier("drugbank.target", "BE/48"), "<https://identifiers.org/drugbank.target:BE%2F48>"},
+		{identifier("mesh", "D000925."), "<https://identifiers.org/mesh:D000925.>"},
+		{RDFResources + "interaction-" + "DB00001~2", "<" + RDFResources + "interaction-DB00001~2>"},
+	} {
+		if term := turtle.iri(test.iri); term != test.turtle {
+			t.Errorf("%s written as %s in Turtle, want %s", test.iri, term, test.turtle)
+		}
+		if term := ntriples.iri(test.iri); term != "<"+test.iri+">" {
+			t.Errorf("%s written as %s in N-Triples", test.iri, term)
+		}
+	}
+
+	value := "Lepirudin \"Refludan\"\r\nC:\\drugs"
+	want := `"Lepirudin \"Refludan\"\r\nC:\\drugs"`
+	if term := turtle.literal(value, ""); term != want {
+		t.Errorf("literal %s, want %s", term, want)
+	}
+	if term := turtle.literal("2005-06-13", xsdDate); term != `"2005-06-13"^^xsd:date` {
+		t.Errorf("date %s in Turtle", term)
+	}
+	if term := ntriples.literal("2005-06-13", xsdDate); term != `"2005-06-13"^^<http://www.w3.org/2001/XMLSchema#date>` {
+		t.Errorf("date %s in N-Triples", term)
+	}
+}
+
+func TestRDFWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "drugbank.nt")
+	writer, err := NewRDFWriter(path, NTriples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []Row{
+		// substances are exact matches, other resources are related,
+		// and unknown resources are kept as literals
+		{Table: "external_identifiers", Value: externalIdentifierRow{"DB00001", ExternalIdentifier{"ChEBI", "CHEBI:142437"}}},
+		{Table: "external_identifiers", Value: externalIdentifierRow{"DB00001", ExternalIdentifier{"UniProtKB", "P01050"}}},
+		{Table: "external_identifiers", Value: externalIdentifierRow{"DB00001", ExternalIdentifier{"Drugs.com", "lepirudin"}}},
+		{Table: "external_identifiers", Value: externalIdentifierRow{"DB00001", ExternalIdentifier{"ChEBI", ""}}},
+		// the interaction listed by both drugs is described once
+		{Table: "drug_interactions", Value: drugInteractionRow{"DB00002", DrugInteraction{"DB00001", "Lepirudin", "Risk of bleeding."}}},
+		{Table: "drug_interactions", Value: drugInteractionRow{"DB00001", DrugInteraction{"DB00002", "Cetuximab", "Risk of bleeding."}}},
+	}
+	for _, row := range rows {
+		if err := writer.Write(row.Table, row.Value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		`<https://identifiers.org/drugbank:DB00001> <http://www.w3.org/2004/02/skos/core#exactMatch> <https://identifiers.org/CHEBI:142437> .`,
+		`<https://identifiers.org/drugbank:DB00001> <http://www.w3.org/2000/01/rdf-schema#seeAlso> <https://identifiers.org/uniprot:P01050> .`,
+		`<https://identifiers.org/drugbank:DB00001> <` + RDFVocabulary + `xref> "Drugs.com:lepirudin" .`,
+		`<https://identifiers.org/drugbank:DB00002> <` + RDFVocabulary + `interactsWith> <https://identifiers.org/drugbank:DB00001> .`,
+		`<` + RDFResources + `interaction-DB00001-DB00002> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <` + RDFVocabulary + `DrugInteraction> .`,
+		`<` + RDFResources + `interaction-DB00001-DB00002> <` + RDFVocabulary + `interactant> <https://identifiers.org/drugbank:DB00002> .`,
+		`<` + RDFResources + `interaction-DB00001-DB00002> <` + RDFVocabulary + `interactant> <https://identifiers.org/drugbank:DB00001> .`,
+		`<` + RDFResources + `interaction-DB00001-DB00002> <http://purl.org/dc/terms/description> "Risk of bleeding." .`,
+		`<https://identifiers.org/drugbank:DB00001> <` + RDFVocabulary + `interactsWith> <https://identifiers.org/drugbank:DB00002> .`,
+	}, "\n") + "\n"
+	if string(data) != want {
+		t.Errorf("triples:\n%s\nwant\n%s", data, want)
+	}
+}
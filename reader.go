@@ -0,0 +1,66 @@
+package drugbank
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Reader decodes drugs one at a time from a drugbank xml document,
+// so that the whole dataset never needs to be held in memory.
+type Reader struct {
+	decoder *xml.Decoder
+}
+
+// NewReader returns a Reader decoding the drugs contained in r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{decoder: xml.NewDecoder(r)}
+}
+
+// Next decodes and returns the next top-level drug in the document.
+// It returns io.EOF once the document has no more drugs, and an error
+// wrapping io.ErrUnexpectedEOF if the document is truncated.
+func (r *Reader) Next() (*Drug, error) {
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return nil, truncated(err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "drug" {
+			continue
+		}
+		d := &Drug{}
+		if err := r.decoder.DecodeElement(d, &start); err != nil {
+			return nil, truncated(err)
+		}
+		return d, nil
+	}
+}
+
+// truncated returns the syntax errors of the decoder reaching the end
+// of its input with elements left open as io.ErrUnexpectedEOF
+func truncated(err error) error {
+	if syntaxErr, ok := err.(*xml.SyntaxError); ok && syntaxErr.Msg == "unexpected EOF" {
+		return fmt.Errorf("line %d: %w", syntaxErr.Line, io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+// Walk calls fn for every drug decoded from r, stopping at
+// the first error returned by either the decoder or fn.
+func Walk(r io.Reader, fn func(*Drug) error) error {
+	reader := NewReader(r)
+	for {
+		d, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+}
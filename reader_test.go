@@ -0,0 +1,94 @@
+package drugbank
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	r := NewReader(strings.NewReader(string(readFixture(t, 2))))
+	var ids []string
+	for {
+		d, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, d.ID)
+	}
+	// nested drug elements, e.g. the members of pathways, are not drugs of the document
+	if got := strings.Join(ids, ","); got != "DB00001,DB00002,DB00003,DB00001,DB00002,DB00003" {
+		t.Errorf("read %s", got)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("read %v past the end, want io.EOF", err)
+	}
+}
+
+func TestReaderEmpty(t *testing.T) {
+	for _, document := range []string{
+		"",
+		`<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<drugbank xmlns="http://www.drugbank.ca" version="5.1"></drugbank>`,
+		`<drugbank version="5.1"/>`,
+	} {
+		if d, err := NewReader(strings.NewReader(document)).Next(); err != io.EOF {
+			t.Errorf("%q: read %v, %v, want io.EOF", document, d, err)
+		}
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	fixture := string(readFixture(t, 1))
+	for _, document := range []string{
+		fixture[:strings.Index(fixture, "<name>Dornase")],   // within a drug
+		fixture[:strings.LastIndex(fixture, "</drugbank>")], // between drugs
+	} {
+		r := NewReader(strings.NewReader(document))
+		var err error
+		drugs := 0
+		for err == nil {
+			if _, err = r.Next(); err == nil {
+				drugs++
+			}
+		}
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("error %v after %d drugs, want io.ErrUnexpectedEOF", err, drugs)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	document := string(readFixture(t, 1))
+	var names []string
+	err := Walk(strings.NewReader(document), func(d *Drug) error {
+		names = append(names, d.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(names, ","); got != "Lepirudin,Cetuximab,Dornase alfa" {
+		t.Errorf("walked %s", got)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = Walk(strings.NewReader(document), func(d *Drug) error {
+		calls++
+		if d.ID == "DB00002" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || calls != 2 {
+		t.Errorf("error %v after %d calls, want the error of the second", err, calls)
+	}
+
+	if err := Walk(strings.NewReader(document[:len(document)/2]), func(*Drug) error { return nil }); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("error %v walking a truncated document", err)
+	}
+}
@@ -0,0 +1,207 @@
+package drugbank
+
+// Table describes an output table: the type of its rows and the
+// vertices and edges of the property graph loaded from it.
+// Schemas for other systems are derived from Tables, so that they
+// cannot disagree with the rows Rows produces.
+type Table struct {
+	Name string
+	Row  interface{} // zero value of the row type
+	// Key lists the columns identifying the rows of tables that list
+	// distinct entities, which are emitted once per key (see Row.Key)
+	Key []string
+	// Owner is the column holding the ID of the drug each row belongs to,
+	// if any. Rows referring to drugs they are not part of, such as the
+	// members of a pathway, have no owner.
+	Owner    string
+	Sequence bool // rows are sequences, written as FASTA
+	Vertices []Vertex
+	Edges    []Edge
+}
+
+// Columns returns the columns of the table
+func (t Table) Columns() []Column {
+	return Columns(t.Row)
+}
+
+// Column returns the column with the given name
+func (t Table) Column(name string) (Column, bool) {
+	for _, column := range t.Columns() {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return Column{}, false
+}
+
+// VertexAttributes returns the columns stored on a vertex loaded
+// from the table, other than its ID, in table order
+func (t Table) VertexAttributes(v Vertex) []Column {
+	var columns []Column
+	for _, column := range t.Columns() {
+		if column.Name == v.ID {
+			continue
+		}
+		if v.Attributes == nil || contains(v.Attributes, column.Name) {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// EdgeAttributes returns the columns stored on an edge
+// loaded from the table, in table order
+func (t Table) EdgeAttributes(e Edge) []Column {
+	var columns []Column
+	for _, column := range t.Columns() {
+		if contains(e.Attributes, column.Name) {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Vertex maps the rows of a table to vertices of a graph
+type Vertex struct {
+	Type string
+	ID   string // column holding the primary ID
+	// Attributes lists the other columns stored on the vertex;
+	// if nil every column but the ID is stored.
+	Attributes []string
+}
+
+// Edge maps the rows of a table to edges of a graph
+type Edge struct {
+	Type       string
+	From, To   string // vertex types
+	FromID     string // column holding the ID of the source vertex
+	ToID       string // column holding the ID of the target vertex
+	Directed   bool
+	Reverse    string // type of the reverse edge of directed edges, if any
+	Attributes []string
+}
+
+// Tables lists the output tables Rows fans drugs out to
+var Tables = []Table{
+	{Name: "drugs", Row: Drug{}, Owner: "drugbank-id", Key: []string{"drugbank-id"}, Vertices: []Vertex{{Type: "Drug", ID: "drugbank-id"}}},
+	{Name: "drug_ids", Row: drugIDRow{}, Owner: "drugbank-id"},
+	{Name: "classifications", Row: classificationRow{}, Owner: "drugbank-id"},
+	{Name: "manufacturers", Row: Manufacturer{}, Vertices: []Vertex{{Type: "Manufacturer", ID: "name"}}},
+	{Name: "drugs-manufacturers-join", Row: drugManufacturerRow{}, Owner: "drugbank-id", Edges: []Edge{
+		{Type: "Manufactures", From: "Manufacturer", FromID: "manufacturer-id", To: "Drug", ToID: "drugbank-id", Directed: true, Reverse: "Manufactured"},
+	}},
+	{Name: "products", Row: Product{}, Vertices: []Vertex{{Type: "Product", ID: "name"}}},
+	{Name: "drugs-products-join", Row: drugProductRow{}, Owner: "drugbank-id", Edges: []Edge{
+		{Type: "In_Product", From: "Drug", FromID: "drugbank-id", To: "Product", ToID: "name", Directed: true, Reverse: "Product_Contains"},
+	}},
+	{Name: "reactions", Row: reactionRow{}, Edges: []Edge{
+		{Type: "Reacts", From: "Drug", FromID: "left-id", To: "Drug", ToID: "right-id", Attributes: []string{"sequence"}},
+	}},
+	{Name: "adverse-reactions", Row: adverseReactionRow{}, Owner: "drugbank-id", Edges: []Edge{
+		{Type: "Adverse_Reacts", From: "Drug", FromID: "drugbank-id", To: "Polypeptide", ToID: "uniprot-id",
+			Attributes: []string{"allele", "adverse-reaction", "description", "pubmed-id"}},
+	}},
+	{Name: "snp-effects", Row: snpEffectRow{}, Owner: "drugbank-id", Edges: []Edge{
+		{Type: "Has_Effect", From: "Drug", FromID: "drugbank-id", To: "Polypeptide", ToID: "uniprot-id",
+			Attributes: []string{"rs-id", "allele", "defining-change", "description", "pubmed-id"}},
+	}},
+	{Name: "groups", Row: groupRow{}, Owner: "drugbank-id"},
+	{Name: "books", Row: bookRow{}, Owner: "drugbank-id",
+		Vertices: []Vertex{{Type: "Book", ID: "isbn", Attributes: []string{"citation"}}},
+		Edges: []Edge{
+			{Type: "Book_About", From: "Book", FromID: "isbn", To: "Drug", ToID: "drugbank-id", Directed: true, Reverse: "In_Book"},
+		}},
+	{Name: "links", Row: linkRow{}, Owner: "drugbank-id", Edges: []Edge{
+		{Type: "Linked", From: "Drug", FromID: "drugbank-id", To: "Link", ToID: "title"},
+	}},
+	{Name: "links_resources", Row: Link{}, Key: []string{"title"}, Vertices: []Vertex{{Type: "Link", ID: "title"}}},
+	{Name: "articles", Row: articleRow{}, Owner: "drugbank-id",
+		Vertices: []Vertex{{Type: "Article", ID: "pubmed-id", Attributes: []string{"citation"}}},
+		Edges: []Edge{
+			{Type: "Article_About", From: "Article", FromID: "pubmed-id", To: "Drug", ToID: "drugbank-id", Directed: true, Reverse: "In_Article"},
+		}},
+	{Name: "synonyms", Row: synonymRow{}, Owner: "drugbank-id"},
+	{Name: "mixtures", Row: mixtureRow{}, Owner: "drugbank-id",
+		Vertices: []Vertex{{Type: "Mixture", ID: "name", Attributes: []string{"ingredients"}}},
+		Edges: []Edge{
+			{Type: "In_Mixture", From: "Drug", FromID: "drugbank-id", To: "Mixture", ToID: "name", Directed: true, Reverse: "Mixture_Contains"},
+		}},
+	{Name: "packagers", Row: packagerRow{}, Owner: "drugbank-id", Edges: []Edge{
+		{Type: "Packaged", From: "Drug", FromID: "drugbank-id", To: "Packager", ToID: "name"},
+	}},
+	{Name: "packagers_resources", Row: Packager{}, Key: []string{"name"}, Vertices: []Vertex{{Type: "Packager", ID: "name"}}},
+	{Name: "prices", Row: priceRow{}, Owner: "drugbank-id",
+		Vertices: []Vertex{{Type: "Price", ID: "description", Attributes: []string{"cost", "currency", "sale-unit"}}},
+		Edges: []Edge{
+			{Type: "Costs", From: "Drug", FromID: "drugbank-id", To: "Price", ToID: "description"},
+		}},
+	{Name: "categories", Row: categoryRow{}, Owner: "drugbank-id"},
+	{Name: "organisms", Row: organismRow{}, Owner: "drugbank-id", Edges: []Edge{
+		{Type: "Affects", From: "Drug", FromID: "drugbank-id", To: "Organism", ToID: "organism", Directed: true, Reverse: "Affected_By"},
+	}},
+	{Name: "organisms_resources", Row: organismResourceRow{}, Key: []string{"organism"}, Vertices: []Vertex{{Type: "Organism", ID: "organism"}}},
+	{Name: "atc_codes", Row: atcCodeRow{}, Owner: "drugbank-id"},
+	{Name: "atc_levels", Row: atcLevelRow{}},
+	{Name: "dosages", Row: dosageRow{}, Owner: "drugbank-id"},
+	{Name: "patents", Row: patentRow{}, Owner: "drugbank-id",
+		Vertices: []Vertex{{Type: "Patent", ID: "number", Attributes: []string{"country", "approved", "expiration", "pediatric"}}},
+		Edges: []Edge{
+			{Type: "Referred_By", From: "Drug", FromID: "drugbank-id", To: "Patent", ToID: "number", Directed: true, Reverse: "Refers"},
+		}},
+	{Name: "drug_interactions", Row: drugInteractionRow{}, Owner: "drugbank-id", Edges: []Edge{
+		{Type: "Interacts", From: "Drug", FromID: "drugbank-id", To: "Drug", ToID: "reagent-id", Attributes: []string{"description"}},
+	}},
+	{Name: "food_interactions", Row: foodInteractionRow{}, Owner: "drugbank-id"},
+	{Name: "experimental_properties", Row: propertyRow{}, Owner: "drugbank-id"},
+	{Name: "calculated_properties", Row: propertyRow{}, Owner: "drugbank-id"},
+	{Name: "structures", Row: structureRow{}, Owner: "drugbank-id"},
+	{Name: "external_links", Row: externalLinkRow{}, Owner: "drugbank-id", Edges: []Edge{
+		{Type: "ExtLink", From: "ExternalLink", FromID: "resource", To: "Drug", ToID: "drugbank-id", Directed: true, Reverse: "In_Link"},
+	}},
+	{Name: "external_links_resources", Row: ExternalLink{}, Key: []string{"resource"}, Vertices: []Vertex{{Type: "ExternalLink", ID: "resource"}}},
+	{Name: "external_identifiers", Row: externalIdentifierRow{}, Owner: "drugbank-id", Edges: []Edge{
+		{Type: "ExtIdentifier", From: "ExternalIdentifier", FromID: "resource", To: "Drug", ToID: "drugbank-id",
+			Directed: true, Reverse: "In_External", Attributes: []string{"identifier"}},
+	}},
+	{Name: "external_identifiers_resource", Row: resourceRow{}, Key: []string{"resource"}, Vertices: []Vertex{{Type: "ExternalIdentifier", ID: "resource"}}},
+	{Name: "pathways", Row: pathwayRow{}, Key: []string{"smpdb-id"}, Vertices: []Vertex{{Type: "Pathway", ID: "smpdb-id"}}},
+	{Name: "pathway_drugs", Row: pathwayDrugRow{}, Key: []string{"smpdb-id", "drugbank-id"}, Edges: []Edge{
+		{Type: "Participates_In", From: "Drug", FromID: "drugbank-id", To: "Pathway", ToID: "smpdb-id", Directed: true, Reverse: "Has_Participant"},
+	}},
+	{Name: "pathway_enzymes", Row: pathwayEnzymeRow{}, Key: []string{"smpdb-id", "uniprot-id"}, Edges: []Edge{
+		{Type: "Participates_In", From: "Polypeptide", FromID: "uniprot-id", To: "Pathway", ToID: "smpdb-id", Directed: true, Reverse: "Has_Participant"},
+	}},
+	{Name: "polypeptide_pfams", Row: pfamRow{}, Key: []string{"uniprot-id", "identifier"}},
+	{Name: "polypeptide_go", Row: goClassifierRow{}, Key: []string{"uniprot-id", "category", "description"}},
+	{Name: "polypeptide_synonyms", Row: polypeptideSynonymRow{}, Key: []string{"uniprot-id", "synonym"}},
+	{Name: "polypeptide_external_ids", Row: polypeptideExternalIDRow{}, Key: []string{"uniprot-id", "resource", "identifier"}},
+	{Name: "polypeptide_sequences", Row: sequenceRow{}, Key: []string{"uniprot-id"}, Sequence: true},
+	{Name: "gene_sequences", Row: sequenceRow{}, Key: []string{"uniprot-id"}, Sequence: true},
+	{Name: "bio_entity_polypeptides", Row: bioEntityPolypeptideRow{}, Key: []string{"bio-entity-id", "uniprot-id"}},
+	{Name: "polypeptides", Row: polypeptideRow{}, Key: []string{"uniprot-id"}, Vertices: []Vertex{
+		{Type: "Polypeptide", ID: "uniprot-id", Attributes: []string{"name", "gene-name", "organism"}},
+	}},
+	{Name: "drug_targets", Row: bioEntityRow{}, Owner: "drugbank-id"},
+	{Name: "drug_enzymes", Row: drugEnzymeRow{}, Owner: "drugbank-id"},
+	{Name: "drug_carriers", Row: bioEntityRow{}, Owner: "drugbank-id"},
+	{Name: "drug_transporters", Row: bioEntityRow{}, Owner: "drugbank-id"},
+}
+
+// LookupTable returns the table with the given name
+func LookupTable(name string) (Table, bool) {
+	for _, table := range Tables {
+		if table.Name == name {
+			return table, true
+		}
+	}
+	return Table{}, false
+}
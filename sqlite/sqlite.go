@@ -0,0 +1,226 @@
+// Package sqlite writes the drugbank output tables to a SQLite database,
+// with primary keys, foreign keys to the drugs table, indexes on the
+// columns tables are joined on and a full text index over drugs.
+//
+// The full text index uses FTS5, which go-sqlite3 only includes
+// when built with the sqlite_fts5 tag:
+//
+//	go build -tags sqlite_fts5 ./cmd/drugbank
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	drugbank "github.com/iz4vve/drugbank-dataset-parser"
+	_ "github.com/mattn/go-sqlite3" // database/sql driver
+)
+
+// sql types of the column kinds. Dates are stored as ISO-8601 text.
+var types = map[drugbank.Kind]string{
+	drugbank.KindString: "TEXT",
+	drugbank.KindBool:   "INTEGER",
+	drugbank.KindInt:    "INTEGER",
+	drugbank.KindFloat:  "REAL",
+	drugbank.KindDate:   "TEXT",
+}
+
+// fullText lists the columns of drugs indexed for full text search
+var fullText = []string{"description", "indication", "mechanism-of-action"}
+
+// Writer is a drugbank.TableWriter inserting rows into a SQLite database.
+// Rows are inserted in a single transaction, committed on Close,
+// after which the indexes are built.
+type Writer struct {
+	path       string
+	db         *sql.DB
+	tx         *sql.Tx
+	tables     []drugbank.Table
+	statements map[string]*statement
+}
+
+// statement inserts rows into a table
+type statement struct {
+	stmt    *sql.Stmt
+	columns []drugbank.Column
+	args    []interface{}
+}
+
+// Create creates the database at path, replacing any existing file,
+// along with one table for each of tables, and returns a Writer to it
+func Create(path string, tables []drugbank.Table) (*Writer, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	// a single connection, as the database is written by one transaction
+	db.SetMaxOpenConns(1)
+	for _, pragma := range []string{"PRAGMA journal_mode = OFF", "PRAGMA synchronous = OFF"} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	for _, table := range tables {
+		if _, err := db.Exec(createTable(table)); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("%s: %v", table.Name, err)
+		}
+	}
+	// created before any row is inserted, to fail early without FTS5
+	if _, err := db.Exec(createFullText()); err != nil {
+		db.Close()
+		if strings.Contains(err.Error(), "no such module") {
+			return nil, fmt.Errorf("%v: build with -tags sqlite_fts5 for full text search", err)
+		}
+		return nil, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Writer{path, db, tx, tables, map[string]*statement{}}, nil
+}
+
+// sqlName returns the sql name of a table or column
+func sqlName(name string) string {
+	return strings.Replace(name, "-", "_", -1)
+}
+
+// createTable returns the statement creating a table. Tables with a
+// key use it as their primary key, other tables get an id column.
+// Every drugbank-id column references drugs; the constraint is only
+// checked if foreign keys are enabled when querying the database.
+func createTable(table drugbank.Table) string {
+	var definitions []string
+	if len(table.Key) == 0 {
+		definitions = append(definitions, "id INTEGER PRIMARY KEY")
+	}
+	for _, column := range table.Columns() {
+		definitions = append(definitions, sqlName(column.Name)+" "+types[column.Kind])
+	}
+	if len(table.Key) > 0 {
+		var key []string
+		for _, name := range table.Key {
+			key = append(key, sqlName(name))
+		}
+		definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(key, ", ")))
+	}
+	if table.Name != "drugs" {
+		if _, ok := table.Column("drugbank-id"); ok {
+			definitions = append(definitions, "FOREIGN KEY (drugbank_id) REFERENCES drugs(drugbank_id)")
+		}
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n\t%s\n)", sqlName(table.Name), strings.Join(definitions, ",\n\t"))
+}
+
+// createIndexes returns the statements creating an index on each of the
+// columns a table is joined on, i.e. the ones holding IDs, unless the
+// column is its primary key already
+func createIndexes(table drugbank.Table) []string {
+	var statements []string
+	for _, column := range table.Columns() {
+		if !joinColumn(column.Name) || (len(table.Key) > 0 && table.Key[0] == column.Name) {
+			continue
+		}
+		statements = append(statements, fmt.Sprintf("CREATE INDEX %s_%s ON %s (%s)",
+			sqlName(table.Name), sqlName(column.Name), sqlName(table.Name), sqlName(column.Name)))
+	}
+	return statements
+}
+
+func joinColumn(name string) bool {
+	return strings.HasSuffix(name, "-id") || name == "atc-code"
+}
+
+// createFullText returns the statement creating drugs_fts, a full text
+// index over the descriptions of drugs, filled once they are inserted
+func createFullText() string {
+	var columns []string
+	for _, name := range fullText {
+		columns = append(columns, sqlName(name))
+	}
+	return fmt.Sprintf("CREATE VIRTUAL TABLE drugs_fts USING fts5(%s, content='drugs', content_rowid='rowid')",
+		strings.Join(columns, ", "))
+}
+
+// Write inserts row into the named table
+func (w *Writer) Write(table string, row interface{}) error {
+	s, ok := w.statements[table]
+	if !ok {
+		t, found := drugbank.LookupTable(table)
+		if !found {
+			return fmt.Errorf("unknown table %s", table)
+		}
+		s = &statement{columns: t.Columns()}
+		var names, placeholders []string
+		for _, column := range s.columns {
+			names = append(names, sqlName(column.Name))
+			placeholders = append(placeholders, "?")
+		}
+		stmt, err := w.tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+			sqlName(table), strings.Join(names, ", "), strings.Join(placeholders, ", ")))
+		if err != nil {
+			return fmt.Errorf("%s: %v", table, err)
+		}
+		s.stmt = stmt
+		s.args = make([]interface{}, len(s.columns))
+		w.statements[table] = s
+	}
+	for i, column := range s.columns {
+		s.args[i] = value(column, row)
+	}
+	if _, err := s.stmt.Exec(s.args...); err != nil {
+		return fmt.Errorf("%s: %v", table, err)
+	}
+	return nil
+}
+
+// value returns the value of a column as stored in the database:
+// empty dates are null, and values of composite types json text
+func value(column drugbank.Column, row interface{}) interface{} {
+	switch column.Kind {
+	case drugbank.KindBool, drugbank.KindInt, drugbank.KindFloat:
+		return column.Value(row)
+	case drugbank.KindDate:
+		if date := column.String(row); date != "" {
+			return date
+		}
+		return nil
+	default:
+		return column.String(row)
+	}
+}
+
+// Files returns the path of the database
+func (w *Writer) Files() []string {
+	return []string{w.path}
+}
+
+// Close commits the rows written, builds the indexes and closes the database
+func (w *Writer) Close() error {
+	defer w.db.Close()
+	for _, s := range w.statements {
+		s.stmt.Close()
+	}
+	if err := w.tx.Commit(); err != nil {
+		return err
+	}
+	for _, table := range w.tables {
+		for _, index := range createIndexes(table) {
+			if _, err := w.db.Exec(index); err != nil {
+				return fmt.Errorf("%s: %v", table.Name, err)
+			}
+		}
+	}
+	if _, err := w.db.Exec("INSERT INTO drugs_fts(drugs_fts) VALUES ('rebuild')"); err != nil {
+		return fmt.Errorf("drugs_fts: %v", err)
+	}
+	return w.db.Close()
+}
@@ -0,0 +1,82 @@
+package sqlite
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	drugbank "github.com/iz4vve/drugbank-dataset-parser"
+)
+
+// hasFTS5 returns whether go-sqlite3 was built with the sqlite_fts5 tag
+func hasFTS5(t *testing.T) bool {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query("PRAGMA compile_options")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var option string
+		if err := rows.Scan(&option); err != nil {
+			t.Fatal(err)
+		}
+		if option == "ENABLE_FTS5" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCreate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "drugbank.db")
+
+	w, err := Create(path, drugbank.Tables)
+	if !hasFTS5(t) {
+		if err == nil || !strings.Contains(err.Error(), "sqlite_fts5") {
+			t.Fatalf("error %v, want the sqlite_fts5 tag to be asked for", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	drug := &drugbank.Drug{ID: "DB00001", Name: "Lepirudin", Indication: "heparin-induced thrombocytopenia"}
+	for _, row := range drugbank.Rows(drug) {
+		if err := w.Write(row.Table, row.Value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var id string
+	err = db.QueryRow(`SELECT drugs.drugbank_id FROM drugs_fts
+		JOIN drugs ON drugs.rowid = drugs_fts.rowid
+		WHERE drugs_fts MATCH 'thrombocytopenia'`).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "DB00001" {
+		t.Errorf("found %s, want DB00001", id)
+	}
+}
@@ -0,0 +1,659 @@
+package drugbank
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Row is a single row of an output table
+type Row struct {
+	Table string
+	Value interface{}
+	// Key identifies rows of tables that list distinct entities
+	// (e.g. resources); a Pipeline only emits the first row for each key.
+	Key string
+}
+
+// Rows fans a drug out to the rows of the output tables
+// (drugs, products, drug_interactions, ...) it contributes to.
+func Rows(d *Drug) []Row {
+	var rows []Row
+	add := func(table string, value interface{}) {
+		rows = append(rows, Row{table, value, ""})
+	}
+	addUnique := func(table, key string, value interface{}) {
+		if key != "" {
+			rows = append(rows, Row{table, value, key})
+		}
+	}
+
+	// DRUG
+	addUnique("drugs", d.ID, d)
+
+	// DRUG IDS
+	for _, legacyID := range d.SecondaryIDs {
+		add("drug_ids", drugIDRow{legacyID, d.ID})
+	}
+
+	// CLASSIFICATION
+	add("classifications", classificationRow{d.ID, d.Classification})
+
+	// MANUFACTURERS
+	for _, manufacturer := range d.Manufacturers {
+		if manufacturer.Name == "" {
+			continue
+		}
+		add("manufacturers", manufacturer)
+		add("drugs-manufacturers-join", drugManufacturerRow{d.ID, manufacturer.Name})
+	}
+
+	// PRODUCTS
+	for _, product := range d.Products {
+		add("products", product)
+		add("drugs-products-join", drugProductRow{d.ID, product.Name})
+	}
+
+	// REACTIONS
+	for _, reaction := range d.Reactions {
+		add("reactions", reactionRow{
+			reaction.Sequence,
+			reaction.Left.ID,
+			reaction.Left.Name,
+			reaction.Right.ID,
+			reaction.Right.Name,
+		})
+	}
+
+	// ADVERSE REACTIONS
+	for _, reaction := range d.AdverseReactions {
+		if reaction.UNIPROTID == "" {
+			continue
+		}
+		add("adverse-reactions", adverseReactionRow{d.ID, reaction})
+	}
+
+	// SNP EFFECTS
+	for _, effect := range d.SNPEffects {
+		if effect.UNIPROTID == "" {
+			continue
+		}
+		add("snp-effects", snpEffectRow{d.ID, effect})
+	}
+
+	// GROUPS
+	for _, group := range d.Groups {
+		if group.Name == "" {
+			continue
+		}
+		add("groups", groupRow{d.ID, group.Name})
+	}
+
+	// REFERENCES
+	// BOOKS
+	for _, book := range d.References.Books {
+		if book.ISBN == "" {
+			continue
+		}
+		add("books", bookRow{d.ID, book})
+	}
+
+	// LINKS
+	for _, link := range d.References.Links {
+		if link.URL == "" {
+			continue
+		}
+		add("links", linkRow{d.ID, link})
+		addUnique("links_resources", link.Title, Link{link.Title, host(link.URL)})
+	}
+
+	// PAPERS
+	for _, paper := range d.References.Articles {
+		if paper.PubMedID == "" {
+			continue
+		}
+		add("articles", articleRow{d.ID, paper})
+	}
+
+	// SYNONYMS
+	for _, syn := range d.Synonyms {
+		if syn.Synonym == "" {
+			continue
+		}
+		add("synonyms", synonymRow{d.ID, syn})
+	}
+
+	// MIXTURES
+	for _, mix := range d.Mixtures {
+		if mix.Name == "" {
+			continue
+		}
+		add("mixtures", mixtureRow{d.ID, mix})
+	}
+
+	// PACKAGERS
+	for _, pack := range d.Packagers {
+		if pack.Name == "" {
+			continue
+		}
+		add("packagers", packagerRow{d.ID, pack})
+		addUnique("packagers_resources", pack.Name, Packager{pack.Name, host(pack.URL)})
+	}
+
+	// PRICES
+	for _, price := range d.Prices {
+		if price.Details.Amount == 0.0 {
+			continue
+		}
+		add("prices", priceRow{
+			d.ID,
+			price.Description,
+			price.Details.Amount,
+			price.Details.Currency,
+			price.Unit,
+		})
+	}
+
+	// CATEGORY
+	for _, cat := range d.Categories {
+		if cat.Category == "" {
+			continue
+		}
+		add("categories", categoryRow{d.ID, cat})
+	}
+
+	// AFFECTED ORGANISMS
+	for _, org := range d.AffectedOrganisms {
+		if org.Description == "" {
+			continue
+		}
+		add("organisms", organismRow{d.ID, org.Description})
+		organism := strings.TrimSpace(org.Description)
+		addUnique("organisms_resources", organism, organismResourceRow{organism})
+	}
+
+	// ATC CODES
+	for _, code := range d.ATCCodes {
+		add("atc_codes", atcCodeRow{code.Code.Code, d.ID})
+
+		for _, level := range code.Code.Levels {
+			add("atc_levels", atcLevelRow{code.Code.Code, level.Code, level.Description})
+		}
+	}
+
+	// DOSAGE
+	for _, dosage := range d.Dosages {
+		if dosage.Form == "" {
+			continue
+		}
+		add("dosages", dosageRow{d.ID, dosage})
+	}
+
+	// PATENT
+	for _, patent := range d.Patents {
+		if patent.Number == "" {
+			continue
+		}
+		add("patents", patentRow{d.ID, patent})
+	}
+
+	// DRUG INTERACTION
+	for _, interaction := range d.DrugInteractions {
+		if interaction.ID == "" {
+			continue
+		}
+		add("drug_interactions", drugInteractionRow{d.ID, interaction})
+	}
+
+	// FOOD INTERACTION
+	for _, interaction := range d.FoodInteractions {
+		if interaction == "" {
+			continue
+		}
+		add("food_interactions", foodInteractionRow{d.ID, interaction})
+	}
+
+	// PROPERTIES
+	for _, property := range d.ExperimentalProperties {
+		if property.Value == "" {
+			continue
+		}
+		add("experimental_properties", propertyRow{d.ID, property})
+	}
+
+	for _, property := range d.CalculatedProperties {
+		if property.Value == "" {
+			continue
+		}
+		add("calculated_properties", propertyRow{d.ID, property})
+	}
+
+	// STRUCTURE
+	structure := structureRow{
+		d.ID,
+		d.Calculated("SMILES"),
+		d.Calculated("InChI"),
+		d.Calculated("InChIKey"),
+		d.Calculated("Molecular Formula"),
+	}
+	if structure.SMILES != "" || structure.InChI != "" || structure.InChIKey != "" || structure.Formula != "" {
+		add("structures", structure)
+	}
+
+	// EXTERNAL LINK
+	for _, link := range d.ExternalLinks {
+		if link.URL == "" {
+			continue
+		}
+		add("external_links", externalLinkRow{d.ID, link})
+		addUnique("external_links_resources", link.Resource, ExternalLink{link.Resource, host(link.URL)})
+	}
+
+	// EXTERNAL IDENTIFIERS
+	for _, id := range d.ExternalIdentifiers {
+		if id.Identifier == "" {
+			continue
+		}
+		add("external_identifiers", externalIdentifierRow{d.ID, id})
+		addUnique("external_identifiers_resource", id.Resource, resourceRow{id.Resource})
+	}
+
+	// PATHWAYS
+	for _, pathway := range d.Pathways {
+		if pathway.SMPDBID == "" {
+			continue
+		}
+		addUnique("pathways", pathway.SMPDBID, pathwayRow{pathway.SMPDBID, pathway.Name, pathway.Category})
+		for _, drug := range pathway.Drugs {
+			addUnique("pathway_drugs", pathway.SMPDBID+"|"+drug.ID, pathwayDrugRow{pathway.SMPDBID, drug.ID, drug.Name})
+		}
+		for _, enzyme := range pathway.Enzymes {
+			addUnique("pathway_enzymes", pathway.SMPDBID+"|"+enzyme, pathwayEnzymeRow{pathway.SMPDBID, enzyme})
+		}
+	}
+
+	// POLYPEPTIDE DETAILS
+	addPolypeptideDetails := func(p Polypeptide) {
+		for _, pfam := range p.Pfams {
+			addUnique("polypeptide_pfams", p.ID+"|"+pfam.Identifier, pfamRow{p.ID, pfam.Identifier, pfam.Name})
+		}
+		for _, classifier := range p.GoClassifiers {
+			addUnique("polypeptide_go", p.ID+"|"+classifier.Category+"|"+classifier.Description,
+				goClassifierRow{p.ID, classifier.Category, classifier.Description})
+		}
+		for _, synonym := range p.Synonyms {
+			addUnique("polypeptide_synonyms", p.ID+"|"+synonym, polypeptideSynonymRow{p.ID, synonym})
+		}
+		for _, id := range p.ExternalIdentifiers {
+			addUnique("polypeptide_external_ids", p.ID+"|"+id.Resource+"|"+id.Identifier, polypeptideExternalIDRow{p.ID, id})
+		}
+		if sequence := residues(p.AminoAcidSequence.Sequence); sequence != "" {
+			addUnique("polypeptide_sequences", p.ID, sequenceRow{p.ID, p.GeneName, p.Name, sequence})
+		}
+		if sequence := residues(p.GeneSequence.Sequence); sequence != "" {
+			addUnique("gene_sequences", p.ID, sequenceRow{p.ID, p.GeneName, p.Name, sequence})
+		}
+	}
+
+	// TARGETS, ENZYMES, CARRIERS AND TRANSPORTERS
+	polypeptides := func(entity BioEntity) {
+		for _, polypeptide := range entity.Polypeptides {
+			if polypeptide.ID == "" {
+				continue
+			}
+			addUnique("bio_entity_polypeptides", entity.ID+"|"+polypeptide.ID, bioEntityPolypeptideRow{entity.ID, polypeptide.ID})
+			addUnique("polypeptides", polypeptide.ID, newPolypeptideRow(polypeptide))
+			addPolypeptideDetails(polypeptide)
+		}
+	}
+	for _, target := range d.Targets {
+		if target.ID == "" {
+			continue
+		}
+		add("drug_targets", newBioEntityRow(d.ID, target.BioEntity))
+		polypeptides(target.BioEntity)
+	}
+	for _, enzyme := range d.Enzymes {
+		if enzyme.ID == "" {
+			continue
+		}
+		add("drug_enzymes", drugEnzymeRow{
+			newBioEntityRow(d.ID, enzyme.BioEntity),
+			enzyme.InhibitionStrength,
+			enzyme.InductionStrength,
+		})
+		polypeptides(enzyme.BioEntity)
+	}
+	for _, carrier := range d.Carriers {
+		if carrier.ID == "" {
+			continue
+		}
+		add("drug_carriers", newBioEntityRow(d.ID, carrier.BioEntity))
+		polypeptides(carrier.BioEntity)
+	}
+	for _, transporter := range d.Transporters {
+		if transporter.ID == "" {
+			continue
+		}
+		add("drug_transporters", newBioEntityRow(d.ID, transporter.BioEntity))
+		polypeptides(transporter.BioEntity)
+	}
+
+	return rows
+}
+
+// Row types of the output tables. Tables listing a single entity
+// (manufacturers, products, ...) use the entity type itself, tables
+// linking an entity to a drug embed it next to the drug's ID.
+
+type drugIDRow struct {
+	LegacyID string `json:"legacy-id"`
+	DrugID   string `json:"drugbank-id"`
+}
+
+type classificationRow struct {
+	ID string `json:"drugbank-id"`
+	Classification
+}
+
+type drugManufacturerRow struct {
+	DrugID         string `json:"drugbank-id"`
+	ManufacturerID string `json:"manufacturer-id"`
+}
+
+type drugProductRow struct {
+	DrugID    string `json:"drugbank-id"`
+	ProductID string `json:"name"`
+}
+
+type reactionRow struct {
+	Sequence  string `json:"sequence"`
+	LeftID    string `json:"left-id"`
+	LeftName  string `json:"left-name"`
+	RightID   string `json:"right-id"`
+	RightName string `json:"right-name"`
+}
+
+type adverseReactionRow struct {
+	DrugID string `json:"drugbank-id"`
+	AdverseReaction
+}
+
+type snpEffectRow struct {
+	DrugID string `json:"drugbank-id"`
+	SNPEffect
+}
+
+type groupRow struct {
+	ID   string `json:"drugbank-id"`
+	Name string `json:"name"`
+}
+
+type bookRow struct {
+	DrugID string `json:"drugbank-id"`
+	Book
+}
+
+type linkRow struct {
+	DrugID string `json:"drugbank-id"`
+	Link
+}
+
+type articleRow struct {
+	DrugID string `json:"drugbank-id"`
+	Article
+}
+
+type synonymRow struct {
+	DrugID string `json:"drugbank-id"`
+	Synonym
+}
+
+type mixtureRow struct {
+	DrugID string `json:"drugbank-id"`
+	Mixture
+}
+
+type packagerRow struct {
+	DrugID string `json:"drugbank-id"`
+	Packager
+}
+
+type priceRow struct {
+	DrugID      string  `json:"drugbank-id"`
+	Description string  `json:"description"`
+	Amount      float64 `json:"cost"`
+	Currency    string  `json:"currency"`
+	Unit        string  `json:"sale-unit"`
+}
+
+type categoryRow struct {
+	DrugID string `json:"drugbank-id"`
+	Category
+}
+
+type organismRow struct {
+	DrugID   string `json:"drugbank-id"`
+	Organism string `json:"organism"`
+}
+
+type organismResourceRow struct {
+	Organism string `json:"organism"`
+}
+
+type atcCodeRow struct {
+	ATCCode string `json:"atc-code"`
+	DrugID  string `json:"drugbank-id"`
+}
+
+type atcLevelRow struct {
+	ATCCode      string `json:"atc-code"`
+	ATCLevelCode string `json:"atc-level"`
+	Description  string `json:"description"`
+}
+
+type dosageRow struct {
+	DrugID string `json:"drugbank-id"`
+	Dosage
+}
+
+type patentRow struct {
+	DrugID string `json:"drugbank-id"`
+	Patent
+}
+
+type drugInteractionRow struct {
+	DrugID string `json:"drugbank-id"`
+	DrugInteraction
+}
+
+type foodInteractionRow struct {
+	DrugID      string `json:"drugbank-id"`
+	Interaction string `json:"interaction"`
+}
+
+type propertyRow struct {
+	DrugID string `json:"drugbank-id"`
+	Property
+}
+
+// structureRow pivots the structure identifiers among
+// the calculated properties of a drug
+type structureRow struct {
+	DrugID   string `json:"drugbank-id"`
+	SMILES   string `json:"smiles"`
+	InChI    string `json:"inchi"`
+	InChIKey string `json:"inchikey"`
+	Formula  string `json:"formula"`
+}
+
+type externalLinkRow struct {
+	DrugID string `json:"drugbank-id"`
+	ExternalLink
+}
+
+type externalIdentifierRow struct {
+	DrugID string `json:"drugbank-id"`
+	ExternalIdentifier
+}
+
+type resourceRow struct {
+	Resource string `json:"resource"`
+}
+
+type pathwayRow struct {
+	SMPDBID  string `json:"smpdb-id"`
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+type pathwayDrugRow struct {
+	SMPDBID string `json:"smpdb-id"`
+	DrugID  string `json:"drugbank-id"`
+	Name    string `json:"name"`
+}
+
+type pathwayEnzymeRow struct {
+	SMPDBID   string `json:"smpdb-id"`
+	UNIPROTID string `json:"uniprot-id"`
+}
+
+type pfamRow struct {
+	UNIPROTID  string `json:"uniprot-id"`
+	Identifier string `json:"identifier"`
+	Name       string `json:"name"`
+}
+
+type goClassifierRow struct {
+	UNIPROTID   string `json:"uniprot-id"`
+	Category    string `json:"category"`
+	Description string `json:"description"`
+}
+
+type polypeptideSynonymRow struct {
+	UNIPROTID string `json:"uniprot-id"`
+	Synonym   string `json:"synonym"`
+}
+
+type polypeptideExternalIDRow struct {
+	UNIPROTID string `json:"uniprot-id"`
+	ExternalIdentifier
+}
+
+type bioEntityPolypeptideRow struct {
+	BioEntityID string `json:"bio-entity-id"`
+	UNIPROTID   string `json:"uniprot-id"`
+}
+
+// bioEntityRow links a drug to a target, enzyme, carrier or transporter
+type bioEntityRow struct {
+	DrugID      string `json:"drugbank-id"`
+	BioEntityID string `json:"bio-entity-id"`
+	Name        string `json:"name"`
+	Organism    string `json:"organism"`
+	Position    string `json:"position"`
+	KnownAction string `json:"known-action"`
+	Actions     string `json:"actions"` // separated by |
+}
+
+// drugEnzymeRow links a drug to an enzyme, with the strength
+// of the drug's inhibition or induction of the enzyme
+type drugEnzymeRow struct {
+	bioEntityRow
+	InhibitionStrength string `json:"inhibition-strength"`
+	InductionStrength  string `json:"induction-strength"`
+}
+
+func newBioEntityRow(drugID string, entity BioEntity) bioEntityRow {
+	return bioEntityRow{
+		drugID,
+		entity.ID,
+		entity.Name,
+		entity.Organism,
+		entity.Position,
+		entity.KnownAction,
+		strings.Join(entity.Actions, "|"),
+	}
+}
+
+// polypeptideRow describes a polypeptide, identified by its UniProt ID
+type polypeptideRow struct {
+	UNIPROTID            string `json:"uniprot-id"`
+	Source               string `json:"source"`
+	Name                 string `json:"name"`
+	GeneName             string `json:"gene-name"`
+	GeneralFunction      string `json:"general-function"`
+	SpecificFunction     string `json:"specific-function"`
+	Locus                string `json:"locus"`
+	CellularLocation     string `json:"cellular-location"`
+	TransmembraneRegions string `json:"transmembrane-regions"`
+	SignalRegions        string `json:"signal-regions"`
+	TheoreticalPi        string `json:"theoretical-pi"`
+	MolecularWeight      string `json:"molecular-weight"`
+	ChromosomeLocation   string `json:"chromosome-location"`
+	Organism             string `json:"organism"`
+	TaxonomyID           string `json:"ncbi-taxonomy-id"`
+}
+
+func newPolypeptideRow(p Polypeptide) polypeptideRow {
+	return polypeptideRow{
+		p.ID,
+		p.Source,
+		p.Name,
+		p.GeneName,
+		p.GeneralFunction,
+		p.SpecificFunction,
+		p.Locus,
+		p.CellularLocation,
+		p.TransmembraneRegions,
+		p.SignalRegion,
+		p.TheoreticalPi,
+		p.MolecularWeight,
+		p.ChromosomeLocation,
+		p.OrganismTaxonomy.Organism,
+		p.OrganismTaxonomy.TaxonomyID,
+	}
+}
+
+// sequenceRow is the amino acid or gene sequence of a polypeptide.
+// File based TableWriters write tables of sequences as FASTA files.
+type sequenceRow struct {
+	UNIPROTID string `json:"uniprot-id"`
+	GeneName  string `json:"gene-name"`
+	Name      string `json:"name"`
+	Sequence  string `json:"sequence"`
+}
+
+// FASTA returns the header and the sequence of a FASTA record
+func (s sequenceRow) FASTA() (string, string) {
+	header := s.UNIPROTID
+	if s.GeneName != "" {
+		header += "|" + s.GeneName
+	}
+	if s.Name != "" {
+		header += " " + s.Name
+	}
+	return header, s.Sequence
+}
+
+// residues strips the FASTA header and line breaks DrugBank
+// includes in its sequences, leaving the bare residues
+func residues(fasta string) string {
+	var sequence strings.Builder
+	for _, line := range strings.Split(fasta, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, ">") {
+			continue
+		}
+		sequence.WriteString(line)
+	}
+	return sequence.String()
+}
+
+// host returns the host of a url, or an empty string if it has none
+func host(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -0,0 +1,289 @@
+package drugbank
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+// drugRows decodes a drug element and returns the rows a Pipeline
+// would write for it, by table
+func drugRows(t *testing.T, element string) map[string][]interface{} {
+	t.Helper()
+	var d Drug
+	if err := xml.Unmarshal([]byte(element), &d); err != nil {
+		t.Fatal(err)
+	}
+	rows := map[string][]interface{}{}
+	for _, row := range unique(Rows(&d), map[string]map[string]bool{}) {
+		rows[row.Table] = append(rows[row.Table], row.Value)
+	}
+	return rows
+}
+
+// checkRows checks the rows of tables, in order
+func checkRows(t *testing.T, rows, want map[string][]interface{}) {
+	t.Helper()
+	for table, values := range want {
+		if !reflect.DeepEqual(rows[table], values) {
+			t.Errorf("%s: rows\n%+v\nwant\n%+v", table, rows[table], values)
+		}
+	}
+}
+
+// prothrombin is a polypeptide shared by a target and an enzyme
+const prothrombin = `<polypeptide id="P00734" source="Swiss-Prot">
+      <name>Prothrombin</name>
+      <general-function>Thrombospondin receptor activity</general-function>
+      <specific-function>Thrombin cleaves fibrinogen into fibrin.</specific-function>
+      <gene-name>F2</gene-name>
+      <locus>11p11</locus>
+      <cellular-location>Secreted</cellular-location>
+      <transmembrane-regions/>
+      <signal-regions>1-24</signal-regions>
+      <theoretical-pi>5.41</theoretical-pi>
+      <molecular-weight>70036.295</molecular-weight>
+      <chromosome-location>11</chromosome-location>
+      <organism ncbi-taxonomy-id="9606">Humans</organism>
+    </polypeptide>`
+
+const bioEntityDrug = `<drug>
+  <drugbank-id primary="true">DB00001</drugbank-id>
+  <name>Lepirudin</name>
+  <targets>
+    <target position="1">
+      <id>BE0000048</id>
+      <name>Prothrombin</name>
+      <organism>Humans</organism>
+      <actions><action>inhibitor</action><action>antagonist</action></actions>
+      <known-action>yes</known-action>
+      ` + prothrombin + `
+    </target>
+    <target><name>Unidentified</name></target>
+  </targets>
+  <enzymes>
+    <enzyme>
+      <id>BE0000296</id>
+      <name>Thrombin</name>
+      <organism>Humans</organism>
+      <known-action>unknown</known-action>
+      <inhibition-strength>strong</inhibition-strength>
+      ` + prothrombin + `
+      <polypeptide source="Swiss-Prot"><name>Unidentified</name></polypeptide>
+    </enzyme>
+  </enzymes>
+  <carriers>
+    <carrier position="1"><id>BE0000530</id><name>Serum albumin</name><organism>Humans</organism><known-action>no</known-action></carrier>
+  </carriers>
+  <transporters>
+    <transporter><id>BE0001032</id><name>Multidrug resistance protein 1</name><organism>Humans</organism><actions><action>substrate</action></actions></transporter>
+  </transporters>
+</drug>`
+
+func TestBioEntityRows(t *testing.T) {
+	thrombin := bioEntityRow{"DB00001", "BE0000296", "Thrombin", "Humans", "", "unknown", ""}
+	checkRows(t, drugRows(t, bioEntityDrug), map[string][]interface{}{
+		// targets without an ID are left out
+		"drug_targets": {
+			bioEntityRow{"DB00001", "BE0000048", "Prothrombin", "Humans", "1", "yes", "inhibitor|antagonist"},
+		},
+		"drug_enzymes": {drugEnzymeRow{thrombin, "strong", ""}},
+		"drug_carriers": {
+			bioEntityRow{"DB00001", "BE0000530", "Serum albumin", "Humans", "1", "no", ""},
+		},
+		"drug_transporters": {
+			bioEntityRow{"DB00001", "BE0001032", "Multidrug resistance protein 1", "Humans", "", "", "substrate"},
+		},
+		// the polypeptide shared by both entities is written once,
+		// and polypeptides without a UniProt ID are left out
+		"bio_entity_polypeptides": {
+			bioEntityPolypeptideRow{"BE0000048", "P00734"},
+			bioEntityPolypeptideRow{"BE0000296", "P00734"},
+		},
+		"polypeptides": {
+			polypeptideRow{"P00734", "Swiss-Prot", "Prothrombin", "F2", "Thrombospondin receptor activity",
+				"Thrombin cleaves fibrinogen into fibrin.", "11p11", "Secreted", "", "1-24", "5.41",
+				"70036.295", "11", "Humans", "9606"},
+		},
+	})
+}
+
+const polypeptideDrug = `<drug>
+  <drugbank-id primary="true">DB00001</drugbank-id>
+  <name>Lepirudin</name>
+  <targets>
+    <target>
+      <id>BE0000048</id>
+      <name>Prothrombin</name>
+      <polypeptide id="P00734" source="Swiss-Prot">
+        <name>Prothrombin</name>
+        <gene-name>F2</gene-name>
+        <external-identifiers>
+          <external-identifier><resource>HUGO Gene Nomenclature Committee (HGNC)</resource><identifier>HGNC:3535</identifier></external-identifier>
+          <external-identifier><resource>UniProtKB</resource><identifier>P00734</identifier></external-identifier>
+        </external-identifiers>
+        <synonyms><synonym>Coagulation factor II</synonym><synonym>3.4.21.5</synonym><synonym>Coagulation factor II</synonym></synonyms>
+        <amino-acid-sequence format="FASTA">&gt;lcl|BSEQ0016004|Prothrombin
+MAHVRGLQLPGCLALAALCSLVHSQHVFLAPQQARSLLQRVRRANTFLEEVRKGNLEREC
+VEETCSYEEAFEALESS</amino-acid-sequence>
+        <gene-sequence format="FASTA">&gt;lcl|BSEQ0016005|Prothrombin (F2)
+ATGGCGCACGTCCGAGGCTTGCAGCTGCCTGGCTGCCTGGCCCTGGCTGCCCTGTGTAGC</gene-sequence>
+        <pfams>
+          <pfam><identifier>PF00594</identifier><name>Gla</name></pfam>
+          <pfam><identifier>PF00051</identifier><name>Kringle</name></pfam>
+        </pfams>
+        <go-classifiers>
+          <go-classifier><category>component</category><description>extracellular space</description></go-classifier>
+          <go-classifier><category>function</category><description>calcium ion binding</description></go-classifier>
+          <go-classifier><category>component</category><description>extracellular space</description></go-classifier>
+        </go-classifiers>
+      </polypeptide>
+      <polypeptide id="P05164" source="Swiss-Prot">
+        <name>Myeloperoxidase</name>
+        <amino-acid-sequence format="FASTA"/>
+      </polypeptide>
+    </target>
+  </targets>
+</drug>`
+
+func TestPolypeptideRows(t *testing.T) {
+	rows := drugRows(t, polypeptideDrug)
+	checkRows(t, rows, map[string][]interface{}{
+		"polypeptide_pfams": {
+			pfamRow{"P00734", "PF00594", "Gla"},
+			pfamRow{"P00734", "PF00051", "Kringle"},
+		},
+		// repeated classifiers and synonyms are written once
+		"polypeptide_go": {
+			goClassifierRow{"P00734", "component", "extracellular space"},
+			goClassifierRow{"P00734", "function", "calcium ion binding"},
+		},
+		"polypeptide_synonyms": {
+			polypeptideSynonymRow{"P00734", "Coagulation factor II"},
+			polypeptideSynonymRow{"P00734", "3.4.21.5"},
+		},
+		"polypeptide_external_ids": {
+			polypeptideExternalIDRow{"P00734", ExternalIdentifier{Resource: "HUGO Gene Nomenclature Committee (HGNC)", Identifier: "HGNC:3535"}},
+			polypeptideExternalIDRow{"P00734", ExternalIdentifier{Resource: "UniProtKB", Identifier: "P00734"}},
+		},
+		// sequences keep their residues only, and empty ones are left out
+		"polypeptide_sequences": {
+			sequenceRow{"P00734", "F2", "Prothrombin",
+				"MAHVRGLQLPGCLALAALCSLVHSQHVFLAPQQARSLLQRVRRANTFLEEVRKGNLERECVEETCSYEEAFEALESS"},
+		},
+		"gene_sequences": {
+			sequenceRow{"P00734", "F2", "Prothrombin", "ATGGCGCACGTCCGAGGCTTGCAGCTGCCTGGCTGCCTGGCCCTGGCTGCCCTGTGTAGC"},
+		},
+	})
+	if len(rows["polypeptides"]) != 2 {
+		t.Errorf("polypeptides %+v, want P00734 and P05164", rows["polypeptides"])
+	}
+}
+
+func TestSequenceFASTA(t *testing.T) {
+	for _, test := range []struct {
+		row    sequenceRow
+		header string
+	}{
+		{sequenceRow{"P00734", "F2", "Prothrombin", "M"}, "P00734|F2 Prothrombin"},
+		{sequenceRow{"P00734", "", "Prothrombin", "M"}, "P00734 Prothrombin"},
+		{sequenceRow{"P00734", "F2", "", "M"}, "P00734|F2"},
+	} {
+		if header, _ := test.row.FASTA(); header != test.header {
+			t.Errorf("header %q, want %q", header, test.header)
+		}
+	}
+}
+
+const structureDrug = `<drug>
+  <drugbank-id primary="true">DB00006</drugbank-id>
+  <name>Bivalirudin</name>
+  <calculated-properties>
+    <property><kind>logP</kind><value>-14</value><source>ChemAxon</source></property>
+    <property><kind>SMILES</kind><value></value><source>ChemAxon</source></property>
+    <property><kind>SMILES</kind><value>CC[C@H](C)[C@H](NC(=O)CNC(=O)N)C(O)=O</value><source>ChemAxon</source></property>
+    <property><kind>InChIKey</kind><value>OIRCOABEOLEUMC-GEJPAHFPSA-N</value><source>ChemAxon</source></property>
+    <property><kind>Molecular Formula</kind><value>C98H138N24O33</value><source>ChemAxon</source></property>
+  </calculated-properties>
+</drug>`
+
+func TestStructureRows(t *testing.T) {
+	rows := drugRows(t, structureDrug)
+	checkRows(t, rows, map[string][]interface{}{
+		// properties without a value are left out
+		"calculated_properties": {
+			propertyRow{"DB00006", Property{"logP", "-14", "ChemAxon"}},
+			propertyRow{"DB00006", Property{"SMILES", "CC[C@H](C)[C@H](NC(=O)CNC(=O)N)C(O)=O", "ChemAxon"}},
+			propertyRow{"DB00006", Property{"InChIKey", "OIRCOABEOLEUMC-GEJPAHFPSA-N", "ChemAxon"}},
+			propertyRow{"DB00006", Property{"Molecular Formula", "C98H138N24O33", "ChemAxon"}},
+		},
+		"structures": {
+			structureRow{"DB00006", "CC[C@H](C)[C@H](NC(=O)CNC(=O)N)C(O)=O", "", "OIRCOABEOLEUMC-GEJPAHFPSA-N", "C98H138N24O33"},
+		},
+	})
+
+	// drugs without a structure, such as biotech ones, have no structure row
+	rows = drugRows(t, `<drug><drugbank-id primary="true">DB00001</drugbank-id><name>Lepirudin</name></drug>`)
+	if rows["structures"] != nil || rows["calculated_properties"] != nil {
+		t.Errorf("structures %+v, calculated properties %+v, want none", rows["structures"], rows["calculated_properties"])
+	}
+}
+
+const pathwayDrug = `<drug>
+  <drugbank-id primary="true">DB00001</drugbank-id>
+  <name>Lepirudin</name>
+  <pathways>
+    <pathway>
+      <smpdb-id>SMP0000278</smpdb-id>
+      <name>Lepirudin Action Pathway</name>
+      <category>drug_action</category>
+      <drugs>
+        <drug><drugbank-id>DB00001</drugbank-id><name>Lepirudin</name></drug>
+        <drug><drugbank-id>DB01373</drugbank-id><name>Calcium</name></drug>
+      </drugs>
+      <enzymes>
+        <uniprot-id>P00734</uniprot-id>
+        <uniprot-id>P00748</uniprot-id>
+        <uniprot-id>P00734</uniprot-id>
+      </enzymes>
+    </pathway>
+    <pathway><name>Unidentified</name></pathway>
+  </pathways>
+</drug>`
+
+func TestPathwayRows(t *testing.T) {
+	rows := drugRows(t, pathwayDrug)
+	checkRows(t, rows, map[string][]interface{}{
+		// pathways without an SMPDB ID are left out
+		"pathways": {pathwayRow{"SMP0000278", "Lepirudin Action Pathway", "drug_action"}},
+		"pathway_drugs": {
+			pathwayDrugRow{"SMP0000278", "DB00001", "Lepirudin"},
+			pathwayDrugRow{"SMP0000278", "DB01373", "Calcium"},
+		},
+		"pathway_enzymes": {
+			pathwayEnzymeRow{"SMP0000278", "P00734"},
+			pathwayEnzymeRow{"SMP0000278", "P00748"},
+		},
+	})
+
+	// a pathway listed by each of its drugs is written once
+	var first, second Drug
+	if err := xml.Unmarshal([]byte(pathwayDrug), &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal([]byte(pathwayDrug), &second); err != nil {
+		t.Fatal(err)
+	}
+	second.ID = "DB01373"
+	seen := map[string]map[string]bool{}
+	counts := map[string]int{}
+	for _, d := range []*Drug{&first, &second} {
+		for _, row := range unique(Rows(d), seen) {
+			counts[row.Table]++
+		}
+	}
+	if counts["pathways"] != 1 || counts["pathway_drugs"] != 2 || counts["pathway_enzymes"] != 2 {
+		t.Errorf("%d pathways, %d pathway_drugs, %d pathway_enzymes written, want 1, 2 and 2",
+			counts["pathways"], counts["pathway_drugs"], counts["pathway_enzymes"])
+	}
+}
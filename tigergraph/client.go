@@ -0,0 +1,228 @@
+// Package tigergraph loads the parsed drugbank tables into a TigerGraph
+// instance by posting them to a loading job through REST++.
+package tigergraph
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client posts data to the REST++ endpoint of a TigerGraph instance
+type Client struct {
+	Host      string // base url of REST++, e.g. http://localhost:9000
+	Graph     string
+	User      string // credentials for basic authentication, if set
+	Password  string
+	Token     string // REST++ token, if authentication is enabled
+	BatchSize int    // records posted per request
+	Retries   int    // attempts made for each batch before giving up
+	Backoff   time.Duration
+	HTTP      *http.Client
+}
+
+// NewClient returns a Client for the given graph. host may omit
+// the scheme and the port, which default to http and 9000.
+func NewClient(host, graph string) *Client {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	if u, err := url.Parse(host); err == nil && u.Port() == "" {
+		u.Host += ":9000"
+		host = u.String()
+	}
+	return &Client{
+		Host:      strings.TrimRight(host, "/"),
+		Graph:     graph,
+		BatchSize: 10000,
+		Retries:   5,
+		Backoff:   time.Second,
+		HTTP:      &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+// Stats counts what TigerGraph accepted from a file
+type Stats struct {
+	ValidLines    int
+	RejectedLines int
+	Vertices      map[string]int // objects accepted, by vertex type
+	Edges         map[string]int // objects accepted, by edge type
+}
+
+func (s *Stats) add(other Stats) {
+	s.ValidLines += other.ValidLines
+	s.RejectedLines += other.RejectedLines
+	for name, n := range other.Vertices {
+		s.Vertices[name] += n
+	}
+	for name, n := range other.Edges {
+		s.Edges[name] += n
+	}
+}
+
+// Load posts the csv data in r to the file variable of a loading job,
+// in batches of BatchSize records each preceded by the header, and
+// returns the counts reported by TigerGraph.
+func (c *Client) Load(job, variable string, r io.Reader) (Stats, error) {
+	stats := Stats{Vertices: map[string]int{}, Edges: map[string]int{}}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	var batch bytes.Buffer
+	writer := csv.NewWriter(&batch)
+	records := 0
+	flush := func() error {
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+		batchStats, err := c.post(job, variable, batch.Bytes())
+		if err != nil {
+			return err
+		}
+		stats.add(batchStats)
+		batch.Reset()
+		records = 0
+		return nil
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		if records == 0 {
+			writer.Write(header)
+		}
+		writer.Write(record)
+		records++
+		if records >= c.BatchSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+	if records > 0 {
+		if err := flush(); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// post sends a batch to the loading job, retrying with an exponential
+// backoff on network errors and on responses the server may recover from
+func (c *Client) post(job, variable string, data []byte) (Stats, error) {
+	query := url.Values{}
+	query.Set("tag", job)
+	query.Set("filename", variable)
+	query.Set("sep", ",")
+	query.Set("eol", "\n")
+	endpoint := fmt.Sprintf("%s/ddl/%s?%s", c.Host, url.PathEscape(c.Graph), query.Encode())
+
+	backoff := c.Backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		var (
+			stats     Stats
+			retryable bool
+		)
+		stats, retryable, err = c.postOnce(endpoint, data)
+		if err == nil || !retryable || attempt >= c.Retries {
+			return stats, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// response is the body returned by the REST++ ddl endpoint
+type response struct {
+	Error   bool   `json:"error"`
+	Message string `json:"message"`
+	Results []struct {
+		Statistics struct {
+			ValidLine           int `json:"validLine"`
+			RejectLine          int `json:"rejectLine"`
+			FailedConditionLine int `json:"failedConditionLine"`
+			NotEnoughToken      int `json:"notEnoughToken"`
+			InvalidJSON         int `json:"invalidJson"`
+			OversizeToken       int `json:"oversizeToken"`
+			Vertex              []struct {
+				TypeName    string `json:"typeName"`
+				ValidObject int    `json:"validObject"`
+			} `json:"vertex"`
+			Edge []struct {
+				TypeName    string `json:"typeName"`
+				ValidObject int    `json:"validObject"`
+			} `json:"edge"`
+		} `json:"statistics"`
+	} `json:"results"`
+}
+
+// postOnce sends a single request, reporting whether a failure may be retried
+func (c *Client) postOnce(endpoint string, data []byte) (Stats, bool, error) {
+	stats := Stats{Vertices: map[string]int{}, Edges: map[string]int{}}
+	request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return stats, false, err
+	}
+	request.Header.Set("Content-Type", "text/csv")
+	if c.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if c.User != "" {
+		request.SetBasicAuth(c.User, c.Password)
+	}
+
+	resp, err := c.HTTP.Do(request)
+	if err != nil {
+		return stats, true, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return stats, true, err
+	}
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return stats, true, fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return stats, false, fmt.Errorf("%s: %s", resp.Status, body)
+	}
+
+	var r response
+	if err := json.Unmarshal(body, &r); err != nil {
+		return stats, false, fmt.Errorf("invalid response: %v", err)
+	}
+	if r.Error {
+		return stats, false, fmt.Errorf("loading job failed: %s", r.Message)
+	}
+	for _, result := range r.Results {
+		s := result.Statistics
+		stats.ValidLines += s.ValidLine
+		stats.RejectedLines += s.RejectLine + s.FailedConditionLine + s.NotEnoughToken + s.InvalidJSON + s.OversizeToken
+		for _, v := range s.Vertex {
+			stats.Vertices[v.TypeName] += v.ValidObject
+		}
+		for _, e := range s.Edge {
+			stats.Edges[e.TypeName] += e.ValidObject
+		}
+	}
+	return stats, false, nil
+}
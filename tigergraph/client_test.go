@@ -0,0 +1,137 @@
+package tigergraph
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// server is a fake REST++ endpoint answering with the given status codes
+// in turn, then accepting every batch
+type server struct {
+	mu       sync.Mutex
+	statuses []int
+	batches  []string    // bodies of the accepted requests
+	attempts []time.Time // of every request
+	queries  []string
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	s.attempts = append(s.attempts, time.Now())
+	s.queries = append(s.queries, r.URL.Path+"?"+r.URL.RawQuery)
+	if len(s.statuses) > 0 {
+		status := s.statuses[0]
+		s.statuses = s.statuses[1:]
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	s.batches = append(s.batches, string(body))
+	lines := strings.Count(string(body), "\n") - 1 // without the header
+	fmt.Fprintf(w, `{"error": false, "results": [{"statistics": {
+		"validLine": %d, "rejectLine": 1,
+		"vertex": [{"typeName": "Drug", "validObject": %d}],
+		"edge": [{"typeName": "has_target", "validObject": %d}]
+	}}]}`, lines, lines, 2*lines)
+}
+
+func newTestClient(t *testing.T, s *server) *Client {
+	t.Helper()
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	client := NewClient(ts.URL, "drugbank")
+	client.BatchSize = 2
+	client.Retries = 3
+	client.Backoff = 10 * time.Millisecond
+	return client
+}
+
+const data = "drugbank-id,name\nDB00001,Lepirudin\nDB00002,Cetuximab\nDB00003,Dornase alfa\nDB00004,Denileukin diftitox\nDB00005,Etanercept\n"
+
+func TestLoadBatches(t *testing.T) {
+	s := &server{}
+	client := newTestClient(t, s)
+	stats, err := client.Load("load_drugbank", "drugs", strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"drugbank-id,name\nDB00001,Lepirudin\nDB00002,Cetuximab\n",
+		"drugbank-id,name\nDB00003,Dornase alfa\nDB00004,Denileukin diftitox\n",
+		"drugbank-id,name\nDB00005,Etanercept\n",
+	}
+	if len(s.batches) != len(want) {
+		t.Fatalf("%d batches posted, want %d: %q", len(s.batches), len(want), s.batches)
+	}
+	for i := range want {
+		if s.batches[i] != want[i] {
+			t.Errorf("batch %d is %q, want %q", i, s.batches[i], want[i])
+		}
+	}
+	if q := s.queries[0]; !strings.HasPrefix(q, "/ddl/drugbank?") || !strings.Contains(q, "tag=load_drugbank") || !strings.Contains(q, "filename=drugs") {
+		t.Errorf("posted to %s", q)
+	}
+	if stats.ValidLines != 5 || stats.RejectedLines != 3 || stats.Vertices["Drug"] != 5 || stats.Edges["has_target"] != 10 {
+		t.Errorf("stats %+v are not summed across batches", stats)
+	}
+}
+
+func TestLoadEmpty(t *testing.T) {
+	s := &server{}
+	client := newTestClient(t, s)
+	for _, input := range []string{"", "drugbank-id,name\n"} {
+		if _, err := client.Load("load_drugbank", "drugs", strings.NewReader(input)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.attempts) > 0 {
+		t.Errorf("%d requests for no records", len(s.attempts))
+	}
+}
+
+func TestLoadRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		fails    bool
+	}{
+		{"server error", []int{http.StatusInternalServerError, http.StatusBadGateway}, 3, false},
+		{"too many requests", []int{http.StatusTooManyRequests}, 2, false},
+		{"retries exhausted", []int{503, 503, 503, 503}, 3, true},
+		{"client error", []int{http.StatusBadRequest}, 1, true},
+		{"unauthorized", []int{http.StatusUnauthorized}, 1, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &server{statuses: test.statuses}
+			client := newTestClient(t, s)
+			client.BatchSize = 10
+			stats, err := client.Load("load_drugbank", "drugs", strings.NewReader(data))
+			if test.fails != (err != nil) {
+				t.Fatalf("error %v", err)
+			}
+			if len(s.attempts) != test.attempts {
+				t.Fatalf("%d attempts, want %d", len(s.attempts), test.attempts)
+			}
+			if !test.fails && stats.ValidLines != 5 {
+				t.Errorf("%d valid lines, want 5", stats.ValidLines)
+			}
+			// the backoff doubles on every attempt
+			backoff := client.Backoff
+			for i := 1; i < len(s.attempts); i++ {
+				if wait := s.attempts[i].Sub(s.attempts[i-1]); wait < backoff {
+					t.Errorf("attempt %d after %v, want at least %v", i+1, wait, backoff)
+				}
+				backoff *= 2
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package tigergraph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Job is a loading job, as defined in a gsql script
+type Job struct {
+	Name  string
+	Graph string
+	Files []File
+}
+
+// File is a file variable declared by a loading job
+type File struct {
+	Variable string // name of the variable in the job
+	Path     string // path assigned to it in the script
+}
+
+var (
+	jobPattern  = regexp.MustCompile(`CREATE LOADING JOB\s+(\w+)\s+FOR GRAPH\s+(\w+)`)
+	filePattern = regexp.MustCompile(`DEFINE FILENAME\s+(\w+)\s*(?:=\s*"([^"]*)")?\s*;`)
+)
+
+// ParseJob reads the name, graph and file variables of the
+// loading job defined in a gsql script such as load_schema.gsql.
+// Every file variable must be assigned a path, naming the file to load.
+func ParseJob(r io.Reader) (*Job, error) {
+	job := &Job{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if m := jobPattern.FindStringSubmatch(line); m != nil {
+			job.Name, job.Graph = m[1], m[2]
+		}
+		if m := filePattern.FindStringSubmatch(line); m != nil {
+			if strings.TrimSpace(m[2]) == "" {
+				return nil, fmt.Errorf("line %d: no path assigned to file %s", n, m[1])
+			}
+			job.Files = append(job.Files, File{m[1], m[2]})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if job.Name == "" {
+		return nil, fmt.Errorf("no loading job found")
+	}
+	return job, nil
+}
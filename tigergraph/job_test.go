@@ -0,0 +1,47 @@
+package tigergraph
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseJob(t *testing.T) {
+	script, err := os.Open("../load_schema.gsql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer script.Close()
+	job, err := ParseJob(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Name != "load_drugs" || job.Graph != "drugbank" {
+		t.Errorf("job %s for graph %s, want load_drugs for drugbank", job.Name, job.Graph)
+	}
+	if len(job.Files) == 0 || job.Files[0] != (File{"drugs", "csv/drugs.csv"}) {
+		t.Errorf("files %v, want drugs first", job.Files)
+	}
+}
+
+func TestParseJobErrors(t *testing.T) {
+	for name, script := range map[string]string{
+		"no job": `DEFINE FILENAME drugs="csv/drugs.csv";`,
+		// the file would be looked up as "." in the output directory
+		"empty path": "CREATE LOADING JOB load_drugs FOR GRAPH drugbank {\n" +
+			"    DEFINE FILENAME drugs=\"\";\n}",
+		"blank path": "CREATE LOADING JOB load_drugs FOR GRAPH drugbank {\n" +
+			"    DEFINE FILENAME drugs=\"  \";\n}",
+		"no path": "CREATE LOADING JOB load_drugs FOR GRAPH drugbank {\n" +
+			"    DEFINE FILENAME drugs;\n}",
+	} {
+		job, err := ParseJob(strings.NewReader(script))
+		if err == nil {
+			t.Errorf("%s: parsed as %+v", name, job)
+			continue
+		}
+		if name != "no job" && !strings.Contains(err.Error(), "line 2: no path assigned to file drugs") {
+			t.Errorf("%s: error %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package tigergraph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	drugbank "github.com/iz4vve/drugbank-dataset-parser"
+)
+
+const header = "// Generated by drugbank schema --target tigergraph, do not edit.\n"
+
+// gsql types of the column kinds
+var types = map[drugbank.Kind]string{
+	drugbank.KindString: "STRING",
+	drugbank.KindBool:   "BOOL",
+	drugbank.KindInt:    "INT",
+	drugbank.KindFloat:  "DOUBLE",
+	drugbank.KindDate:   "DATETIME",
+}
+
+// vertexType is a vertex type, defined by the first table loading it
+type vertexType struct {
+	table  drugbank.Table
+	vertex drugbank.Vertex
+}
+
+// edgeType is an edge type, which may connect several pairs of vertex types
+type edgeType struct {
+	table drugbank.Table
+	edge  drugbank.Edge
+	pairs [][2]string
+}
+
+// graphSchema collects the vertex and edge types of a list of tables
+type graphSchema struct {
+	vertices []*vertexType
+	edges    []*edgeType
+}
+
+func newGraphSchema(tables []drugbank.Table) (*graphSchema, error) {
+	schema := &graphSchema{}
+	vertices := map[string]*vertexType{}
+	edges := map[string]*edgeType{}
+	for _, table := range tables {
+		for _, vertex := range table.Vertices {
+			if err := checkColumns(table, append([]string{vertex.ID}, vertex.Attributes...)); err != nil {
+				return nil, err
+			}
+			if vertices[vertex.Type] == nil {
+				vertices[vertex.Type] = &vertexType{table, vertex}
+				schema.vertices = append(schema.vertices, vertices[vertex.Type])
+			}
+		}
+		for _, edge := range table.Edges {
+			if err := checkColumns(table, append([]string{edge.FromID, edge.ToID}, edge.Attributes...)); err != nil {
+				return nil, err
+			}
+			e := edges[edge.Type]
+			if e == nil {
+				e = &edgeType{table: table, edge: edge}
+				edges[edge.Type] = e
+				schema.edges = append(schema.edges, e)
+			} else if e.edge.Directed != edge.Directed || e.edge.Reverse != edge.Reverse {
+				return nil, fmt.Errorf("edge %s: %s and %s disagree on its direction", edge.Type, e.table.Name, table.Name)
+			}
+			e.pairs = appendPair(e.pairs, [2]string{edge.From, edge.To})
+		}
+	}
+	for _, e := range schema.edges {
+		for _, pair := range e.pairs {
+			for _, name := range pair {
+				if vertices[name] == nil {
+					return nil, fmt.Errorf("edge %s: unknown vertex %s", e.edge.Type, name)
+				}
+			}
+		}
+	}
+	return schema, nil
+}
+
+func appendPair(pairs [][2]string, pair [2]string) [][2]string {
+	for _, p := range pairs {
+		if p == pair {
+			return pairs
+		}
+	}
+	return append(pairs, pair)
+}
+
+// checkColumns returns an error if a column is missing from the table
+func checkColumns(table drugbank.Table, names []string) error {
+	for _, name := range names {
+		if _, ok := table.Column(name); !ok {
+			return fmt.Errorf("table %s has no column %s", table.Name, name)
+		}
+	}
+	return nil
+}
+
+// identifier turns a column or table name into a gsql identifier
+func identifier(name string) string {
+	return strings.Replace(name, "-", "_", -1)
+}
+
+// WriteSchema writes the gsql statements creating the vertex and
+// edge types described by tables, and a graph containing them
+func WriteSchema(w io.Writer, tables []drugbank.Table, graph string) error {
+	schema, err := newGraphSchema(tables)
+	if err != nil {
+		return err
+	}
+	buffer := bufio.NewWriter(w)
+	fmt.Fprint(buffer, header)
+	for _, v := range schema.vertices {
+		definitions := []string{fmt.Sprintf("PRIMARY_ID %s STRING", identifier(v.vertex.ID))}
+		for _, column := range v.table.VertexAttributes(v.vertex) {
+			definitions = append(definitions, identifier(column.Name)+" "+types[column.Kind])
+		}
+		fmt.Fprintf(buffer, "CREATE VERTEX %s (%s) WITH primary_id_as_attribute=\"true\"\n",
+			v.vertex.Type, strings.Join(definitions, ", "))
+	}
+	fmt.Fprintln(buffer)
+	for _, e := range schema.edges {
+		var pairs []string
+		for _, pair := range e.pairs {
+			pairs = append(pairs, fmt.Sprintf("FROM %s, TO %s", pair[0], pair[1]))
+		}
+		definitions := []string{strings.Join(pairs, " | ")}
+		for _, column := range e.table.EdgeAttributes(e.edge) {
+			definitions = append(definitions, identifier(column.Name)+" "+types[column.Kind])
+		}
+		direction := "UNDIRECTED"
+		if e.edge.Directed {
+			direction = "DIRECTED"
+		}
+		fmt.Fprintf(buffer, "CREATE %s EDGE %s (%s)", direction, e.edge.Type, strings.Join(definitions, ", "))
+		if e.edge.Directed && e.edge.Reverse != "" {
+			fmt.Fprintf(buffer, " WITH REVERSE_EDGE=\"%s\"", e.edge.Reverse)
+		}
+		fmt.Fprintln(buffer)
+	}
+	fmt.Fprintf(buffer, "\nCREATE GRAPH %s (*)\n", graph)
+	return buffer.Flush()
+}
+
+// WriteLoadingJob writes the gsql script creating the loading job
+// job, which loads the csv tables found in dir into graph
+func WriteLoadingJob(w io.Writer, tables []drugbank.Table, graph, job, dir string) error {
+	schema, err := newGraphSchema(tables)
+	if err != nil {
+		return err
+	}
+	buffer := bufio.NewWriter(w)
+	fmt.Fprint(buffer, header)
+	fmt.Fprintf(buffer, "USE GRAPH %s\nBEGIN\nCREATE LOADING JOB %s FOR GRAPH %s {\n", graph, job, graph)
+	for _, table := range tables {
+		if len(table.Vertices) > 0 || len(table.Edges) > 0 {
+			fmt.Fprintf(buffer, "    DEFINE FILENAME %s=\"%s\";\n", identifier(table.Name), path.Join(dir, table.Name+".csv"))
+		}
+	}
+
+	edges := map[string]*edgeType{}
+	for _, e := range schema.edges {
+		edges[e.edge.Type] = e
+	}
+	const using = `USING header="true", separator=",", QUOTE="double"`
+	for _, table := range tables {
+		if len(table.Vertices) == 0 && len(table.Edges) == 0 {
+			continue
+		}
+		fmt.Fprintln(buffer)
+		file := identifier(table.Name)
+		for _, vertex := range table.Vertices {
+			values := []string{column(vertex.ID)}
+			for _, c := range table.VertexAttributes(vertex) {
+				values = append(values, column(c.Name))
+			}
+			fmt.Fprintf(buffer, "    LOAD %s TO VERTEX %s VALUES (%s) %s;\n",
+				file, vertex.Type, strings.Join(values, ", "), using)
+		}
+		for _, edge := range table.Edges {
+			from, to := column(edge.FromID), column(edge.ToID)
+			// edges between several pairs of vertex types
+			// need the types of the vertices they connect
+			if len(edges[edge.Type].pairs) > 1 {
+				from += " " + edge.From
+				to += " " + edge.To
+			}
+			values := []string{from, to}
+			for _, c := range table.EdgeAttributes(edge) {
+				values = append(values, column(c.Name))
+			}
+			fmt.Fprintf(buffer, "    LOAD %s TO EDGE %s VALUES (%s) %s;\n",
+				file, edge.Type, strings.Join(values, ", "), using)
+		}
+	}
+	fmt.Fprint(buffer, "}\nEND\n")
+	return buffer.Flush()
+}
+
+// column references a column of the file being loaded by name
+func column(name string) string {
+	return fmt.Sprintf("$%q", name)
+}
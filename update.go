@@ -0,0 +1,706 @@
+package drugbank
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Changes collects the drugs of a release that were updated since a
+// given date, to merge them into the output of a previous release
+// instead of rewriting every table (see Merge).
+type Changes struct {
+	Since   Date
+	format  string
+	tables  map[string]Table
+	present map[string]bool
+	updated map[string]bool
+	rows    map[string]*encodedRows // rows of the updated drugs, by table
+	// rows of every drug in the tables without owner, to tell the rows
+	// of the base still referred to from the stale ones
+	shared map[string]*encodedRows
+	seen   map[string]map[string]bool // keys of the shared rows, by table
+	dir    string                     // temporary, holding the encoded rows
+	err    error                      // of the first row that could not be encoded
+}
+
+// encodedRows are rows encoded as in the file of their table,
+// spilled to a temporary file until they are merged
+type encodedRows struct {
+	file    *os.File
+	buffer  *bufio.Writer
+	encoder encoder
+}
+
+// NewChanges returns the Changes collecting the drugs updated on or after
+// since, a date in the YYYY-MM-DD format used by DrugBank, to be merged
+// into tables written in format (json, csv or tsv). The rows collected
+// are kept in temporary files, removed by Merge or Close.
+func NewChanges(since, format string) (*Changes, error) {
+	if _, ok := recordFormats[format]; !ok {
+		return nil, fmt.Errorf("cannot merge %s output, use json, csv or tsv", format)
+	}
+	date, err := ParseDate(since)
+	if err != nil {
+		return nil, err
+	}
+	if date.IsZero() {
+		return nil, errors.New("missing date")
+	}
+	c := &Changes{
+		Since:   date,
+		format:  format,
+		tables:  map[string]Table{},
+		present: map[string]bool{},
+		updated: map[string]bool{},
+		rows:    map[string]*encodedRows{},
+		shared:  map[string]*encodedRows{},
+		seen:    map[string]map[string]bool{},
+	}
+	for _, table := range Tables {
+		c.tables[table.Name] = table
+	}
+	return c, nil
+}
+
+// Add records a drug of the release and, if it was updated on or after
+// Since or has no update date, its rows. It returns whether the drug is
+// updated. Since is inclusive, for the date of the last run to be given.
+func (c *Changes) Add(d *Drug) bool {
+	c.present[d.ID] = true
+	updated := d.DrugRecordUpdatedOn.IsZero() || !d.DrugRecordUpdatedOn.Before(c.Since.Time)
+	if updated {
+		c.updated[d.ID] = true
+	}
+	for _, row := range Rows(d) {
+		table := c.tables[row.Table]
+		if updated {
+			c.encode(c.rows, "updated", table, row.Value)
+		}
+		if table.Owner != "" {
+			continue
+		}
+		if row.Key != "" {
+			if c.seen[table.Name] == nil {
+				c.seen[table.Name] = map[string]bool{}
+			}
+			if c.seen[table.Name][row.Key] {
+				continue
+			}
+			c.seen[table.Name][row.Key] = true
+		}
+		c.encode(c.shared, "shared", table, row.Value)
+	}
+	return updated
+}
+
+// encode appends row to the rows of table in set, written to a
+// temporary file named after set and the table
+func (c *Changes) encode(set map[string]*encodedRows, name string, table Table, row interface{}) {
+	if c.err != nil {
+		return
+	}
+	rows, ok := set[table.Name]
+	if !ok {
+		var err error
+		if rows, err = c.createRows(name + "." + table.Name); err != nil {
+			c.err = err
+			return
+		}
+		f, _ := c.recordFormat(table)
+		rows.encoder = f.newEncoder(rows.buffer)
+		set[table.Name] = rows
+	}
+	if err := rows.encoder.Encode(row); err != nil {
+		c.err = fmt.Errorf("%s: %v", table.Name, err)
+	}
+}
+
+// createRows creates a temporary file for encoded rows
+func (c *Changes) createRows(name string) (*encodedRows, error) {
+	if c.dir == "" {
+		dir, err := ioutil.TempDir("", "drugbank-changes")
+		if err != nil {
+			return nil, err
+		}
+		c.dir = dir
+	}
+	file, err := os.Create(filepath.Join(c.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	return &encodedRows{file: file, buffer: bufio.NewWriterSize(file, 64*1024)}, nil
+}
+
+// Close removes the temporary files of the rows collected
+func (c *Changes) Close() error {
+	for _, set := range []map[string]*encodedRows{c.rows, c.shared} {
+		for name, rows := range set {
+			rows.file.Close()
+			delete(set, name)
+		}
+	}
+	if c.dir == "" {
+		return nil
+	}
+	err := os.RemoveAll(c.dir)
+	c.dir = ""
+	return err
+}
+
+// recordFormat returns the format of the file of a table and its extension
+func (c *Changes) recordFormat(table Table) (recordFormat, string) {
+	if table.Sequence {
+		return fastaFormat, ".fasta"
+	}
+	f := recordFormats[c.format]
+	return f, f.extension
+}
+
+// read reads back the rows of table, calling fn with each record
+// as it would be read from the file of the table. rows may be nil.
+func (rows *encodedRows) read(f recordFormat, table Table, fn func(raw []byte, field func(string) string)) error {
+	if rows == nil {
+		return nil
+	}
+	if flusher, ok := rows.encoder.(flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := rows.buffer.Flush(); err != nil {
+		return err
+	}
+	if _, err := rows.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	reader := f.newReader(bufio.NewReaderSize(rows.file, 64*1024), table)
+	for {
+		raw, field, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(raw, field)
+	}
+}
+
+// Updated returns the number of drugs updated since Since
+func (c *Changes) Updated() int {
+	return len(c.updated)
+}
+
+// TableUpdate counts the rows of a table merged by Merge
+type TableUpdate struct {
+	Rows int `json:"rows"` // of the merged table
+	TableChange
+	Files []string `json:"-"` // written: the merged table, then the delta files if any
+}
+
+// Merge merges the changes into the tables written to base by a previous
+// run, writing the merged tables to output, which may be base itself, and
+// the rows added and removed to <table>.added.<ext> and
+// <table>.removed.<ext> in output/delta.
+//
+// Rows are attributed to drugs by the owner column of their table (see
+// Table.Owner): the rows of updated drugs are replaced by their new rows,
+// and the rows of drugs missing from the release, which must therefore
+// be complete, are removed. Rows of tables listing distinct entities
+// (see Table.Key) are replaced by the row with the same key, if any, and
+// kept once per key. Rows of tables without owner (e.g. products or
+// pathways) are removed once no drug of the release has them.
+// The temporary files of the changes are removed once merged.
+func (c *Changes) Merge(base, output string) (map[string]*TableUpdate, error) {
+	defer c.Close()
+	if c.err != nil {
+		return nil, c.err
+	}
+	delta := filepath.Join(output, "delta")
+	if err := os.RemoveAll(delta); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(delta, 0770); err != nil {
+		return nil, err
+	}
+	updates := map[string]*TableUpdate{}
+	for _, table := range Tables {
+		update, err := c.mergeTable(table, base, output)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", table.Name, err)
+		}
+		if update != nil {
+			updates[table.Name] = update
+		}
+	}
+	return updates, nil
+}
+
+// mergeTable merges the changes to a table. It returns nil if the
+// table is neither in base nor in the changes.
+func (c *Changes) mergeTable(table Table, base, output string) (*TableUpdate, error) {
+	f, extension := c.recordFormat(table)
+
+	// the new rows are encoded and read back like the base table,
+	// so that both compare equal whenever they hold the same values
+	changes := newRecordSet(table)
+	if err := c.rows[table.Name].read(f, table, changes.add); err != nil {
+		return nil, err
+	}
+	// the number of rows with each value, or with each key, in the
+	// release, for tables without owner
+	var release map[string]int
+	if table.Owner == "" {
+		release = map[string]int{}
+		err := c.shared[table.Name].read(f, table, func(raw []byte, field func(string) string) {
+			if key := changes.key(field); key != "" {
+				release[key] = 1
+			} else {
+				release[string(raw)]++
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	var header []byte
+	if f.header != nil {
+		header = f.header(table)
+	}
+
+	basePath := filepath.Join(base, table.Name+extension)
+	var baseTable io.Reader = bytes.NewReader(nil)
+	baseFile, err := os.Open(basePath)
+	if err == nil {
+		defer baseFile.Close()
+		baseTable = baseFile
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	} else if len(changes.records) == 0 {
+		return nil, nil
+	}
+
+	merged := newDeltaFile(filepath.Join(output, table.Name+extension+".tmp"), header)
+	added := newDeltaFile(filepath.Join(output, "delta", table.Name+".added"+extension), header)
+	removed := newDeltaFile(filepath.Join(output, "delta", table.Name+".removed"+extension), header)
+	files := []*deltaFile{merged, added, removed}
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	// the merged table is always written, even if it ends up empty
+	if err := merged.create(); err != nil {
+		return nil, err
+	}
+
+	update := &TableUpdate{}
+	write := func(file *deltaFile, raw []byte) error {
+		if file == added {
+			update.Added++
+		}
+		if file == removed {
+			update.Removed++
+		}
+		if file == merged {
+			update.Rows++
+		}
+		return file.Write(raw)
+	}
+
+	written := map[string]bool{} // keys of the rows merged
+	reader := f.newReader(bufio.NewReaderSize(baseTable, 64*1024), table)
+	for {
+		raw, field, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", basePath, err)
+		}
+		key := changes.key(field)
+		switch {
+		case key != "" && written[key]:
+			// a duplicate, left by an older version of the merge
+			err = write(removed, raw)
+		case changes.owned:
+			owner := field(table.Owner)
+			if c.present[owner] && !c.updated[owner] {
+				err = write(merged, raw)
+			} else if changes.take(string(raw)) {
+				err = write(merged, raw)
+			} else {
+				err = write(removed, raw)
+			}
+		case key != "":
+			if release[key] == 0 {
+				err = write(removed, raw) // no drug refers to it anymore
+			} else if replacement, ok := changes.takeKey(key); ok && string(replacement) != string(raw) {
+				if err = write(removed, raw); err == nil {
+					if err = write(added, replacement); err == nil {
+						err = write(merged, replacement)
+					}
+				}
+			} else {
+				err = write(merged, raw)
+			}
+		default:
+			// as many copies of a row are kept as the release has
+			if release[string(raw)] > 0 {
+				release[string(raw)]--
+				err = write(merged, raw)
+			} else {
+				err = write(removed, raw)
+			}
+		}
+		if key != "" {
+			written[key] = true
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, raw := range changes.remaining() {
+		if !changes.owned && len(table.Key) == 0 {
+			if release[string(raw)] == 0 {
+				continue
+			}
+			release[string(raw)]--
+		}
+		if err := write(added, raw); err != nil {
+			return nil, err
+		}
+		if err := write(merged, raw); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, file := range files {
+		if err := file.Close(); err != nil {
+			return nil, err
+		}
+	}
+	path := filepath.Join(output, table.Name+extension)
+	update.Files = append(update.Files, path)
+	for _, file := range []*deltaFile{added, removed} {
+		if file.created {
+			update.Files = append(update.Files, file.path)
+		}
+	}
+	return update, os.Rename(merged.path, path)
+}
+
+// recordSet holds the new records of a table, to be matched
+// against the records of the base table
+type recordSet struct {
+	table   Table
+	owned   bool // rows belong to the drug in their owner column
+	records [][]byte
+	taken   []bool
+	byValue map[string][]int // indexes of the records, by value
+	byKey   map[string]int   // index of the first record with each key
+}
+
+func newRecordSet(table Table) *recordSet {
+	return &recordSet{
+		table:   table,
+		owned:   table.Owner != "",
+		byValue: map[string][]int{},
+		byKey:   map[string]int{},
+	}
+}
+
+// key returns the key of a record, or "" if the table has none
+func (s *recordSet) key(field func(string) string) string {
+	if len(s.table.Key) == 0 {
+		return ""
+	}
+	values := make([]string, len(s.table.Key))
+	for i, column := range s.table.Key {
+		values[i] = field(column)
+	}
+	return strings.Join(values, "\x00")
+}
+
+func (s *recordSet) add(raw []byte, field func(string) string) {
+	if key := s.key(field); key != "" {
+		if _, ok := s.byKey[key]; ok {
+			return // as in a Pipeline, only the first row with a key is kept
+		}
+		s.byKey[key] = len(s.records)
+	}
+	s.byValue[string(raw)] = append(s.byValue[string(raw)], len(s.records))
+	s.records = append(s.records, raw)
+	s.taken = append(s.taken, false)
+}
+
+// take marks a record equal to raw as found in the base table
+func (s *recordSet) take(raw string) bool {
+	for _, i := range s.byValue[raw] {
+		if !s.taken[i] {
+			s.taken[i] = true
+			return true
+		}
+	}
+	return false
+}
+
+// takeKey marks the record with the given key as found in the base table
+func (s *recordSet) takeKey(key string) ([]byte, bool) {
+	i, ok := s.byKey[key]
+	if !ok || s.taken[i] {
+		return nil, false
+	}
+	s.taken[i] = true
+	return s.records[i], true
+}
+
+// remaining returns the records not found in the base table
+func (s *recordSet) remaining() [][]byte {
+	var records [][]byte
+	for i, raw := range s.records {
+		if !s.taken[i] {
+			records = append(records, raw)
+		}
+	}
+	return records
+}
+
+// deltaFile is a table file created on the first record written to it,
+// preceded by the header of the table if any
+type deltaFile struct {
+	path    string
+	header  []byte
+	file    *os.File
+	buffer  *bufio.Writer
+	created bool
+}
+
+func newDeltaFile(path string, header []byte) *deltaFile {
+	return &deltaFile{path: path, header: header}
+}
+
+func (f *deltaFile) create() error {
+	file, err := os.Create(f.path)
+	if err != nil {
+		return err
+	}
+	f.file, f.created = file, true
+	f.buffer = bufio.NewWriterSize(file, 64*1024)
+	_, err = f.buffer.Write(f.header)
+	return err
+}
+
+func (f *deltaFile) Write(raw []byte) error {
+	if f.file == nil {
+		if err := f.create(); err != nil {
+			return err
+		}
+	}
+	_, err := f.buffer.Write(raw)
+	return err
+}
+
+// Close flushes and closes the file, if it was created
+func (f *deltaFile) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.buffer.Flush()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	f.file = nil
+	return err
+}
+
+// recordFormat reads and writes the records of the table files of a format
+type recordFormat struct {
+	extension  string
+	newEncoder func(w io.Writer) encoder
+	newReader  func(r io.Reader, table Table) recordReader
+	header     func(table Table) []byte // of the files, if any
+}
+
+// recordReader reads the records of a table file
+type recordReader interface {
+	// Read returns the next record, as written in the file, and
+	// a function returning the value of a column of the record
+	Read() ([]byte, func(column string) string, error)
+}
+
+// recordFormats are the formats that can be merged, by name
+var recordFormats = map[string]recordFormat{
+	"json": {".json", func(w io.Writer) encoder { return json.NewEncoder(w) }, newJSONReader, nil},
+	"csv":  {".csv", func(w io.Writer) encoder { return &csvEncoder{writer: csv.NewWriter(w)} }, newCSVReader, csvHeader},
+	"tsv":  {".tsv", func(w io.Writer) encoder { return &tsvEncoder{writer: w} }, newTSVReader, nil},
+}
+
+var fastaFormat = recordFormat{".fasta", func(w io.Writer) encoder { return &fastaEncoder{w} }, newFASTAReader, nil}
+
+// csvHeader returns the header written by a csvEncoder
+func csvHeader(table Table) []byte {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	var names []string
+	for _, column := range table.Columns() {
+		names = append(names, column.Name)
+	}
+	writer.Write(names)
+	writer.Flush()
+	return buffer.Bytes()
+}
+
+// lineReader reads records written one per line
+type lineReader struct {
+	reader *bufio.Reader
+	fields func(line []byte) (func(string) string, error)
+}
+
+func (r *lineReader) Read() ([]byte, func(string) string, error) {
+	line, err := r.reader.ReadBytes('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+		line = append(line, '\n')
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	field, err := r.fields(line)
+	return line, field, err
+}
+
+func newJSONReader(r io.Reader, table Table) recordReader {
+	return &lineReader{bufio.NewReader(r), func(line []byte) (func(string) string, error) {
+		var values map[string]interface{}
+		if err := json.Unmarshal(line, &values); err != nil {
+			return nil, err
+		}
+		return func(column string) string {
+			switch value := values[column].(type) {
+			case nil:
+				return ""
+			case string:
+				return value
+			default:
+				return fmt.Sprint(value)
+			}
+		}, nil
+	}}
+}
+
+func newTSVReader(r io.Reader, table Table) recordReader {
+	index := map[string]int{}
+	for i, column := range table.Columns() {
+		index[column.Name] = i
+	}
+	return &lineReader{bufio.NewReader(r), func(line []byte) (func(string) string, error) {
+		values := strings.Split(strings.TrimSuffix(string(line), "\n"), "\t")
+		return func(column string) string {
+			if i, ok := index[column]; ok && i < len(values) {
+				return values[i]
+			}
+			return ""
+		}, nil
+	}}
+}
+
+// csvReader reads csv records, returned as written back by a csv.Writer
+type csvReader struct {
+	reader *csv.Reader
+	index  map[string]int
+	buffer bytes.Buffer
+	writer *csv.Writer
+}
+
+func newCSVReader(r io.Reader, table Table) recordReader {
+	reader := &csvReader{reader: csv.NewReader(r)}
+	reader.reader.FieldsPerRecord = -1
+	reader.writer = csv.NewWriter(&reader.buffer)
+	return reader
+}
+
+func (r *csvReader) Read() ([]byte, func(string) string, error) {
+	if r.index == nil {
+		columns, err := r.reader.Read()
+		if err != nil {
+			return nil, nil, err
+		}
+		r.index = map[string]int{}
+		for i, column := range columns {
+			r.index[column] = i
+		}
+	}
+	values, err := r.reader.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.encode(values), func(column string) string {
+		if i, ok := r.index[column]; ok && i < len(values) {
+			return values[i]
+		}
+		return ""
+	}, nil
+}
+
+func (r *csvReader) encode(values []string) []byte {
+	r.buffer.Reset()
+	r.writer.Write(values)
+	r.writer.Flush()
+	return append([]byte{}, r.buffer.Bytes()...)
+}
+
+// fastaReader reads FASTA records, keyed by the UniProt ID in their header
+type fastaReader struct {
+	reader *bufio.Reader
+}
+
+func newFASTAReader(r io.Reader, table Table) recordReader {
+	return &fastaReader{bufio.NewReader(r)}
+}
+
+func (r *fastaReader) Read() ([]byte, func(string) string, error) {
+	header, err := r.reader.ReadBytes('\n')
+	if err == io.EOF && len(header) > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if header[0] != '>' {
+		return nil, nil, errors.New("invalid FASTA record")
+	}
+	record := header
+	for {
+		next, err := r.reader.Peek(1)
+		if err == io.EOF || (err == nil && next[0] == '>') {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		record = append(record, line...)
+	}
+	id := strings.FieldsFunc(string(header[1:]), func(r rune) bool {
+		return r == '|' || r == ' ' || r == '\n'
+	})
+	return record, func(column string) string {
+		if column == "uniprot-id" && len(id) > 0 {
+			return id[0]
+		}
+		return ""
+	}, nil
+}
@@ -0,0 +1,241 @@
+package drugbank
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// releaseDrugs returns the drugs of a release: DB00001 and DB00002 share
+// a pathway, and in the second release both are updated, DB00002 sells
+// another product and DB00003 is withdrawn along with its product.
+func releaseDrugs(second bool) []*Drug {
+	updated := Date{Time: time.Date(2018, 12, 3, 0, 0, 0, 0, time.UTC)}
+	if second {
+		updated = Date{Time: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}
+	}
+	pathway := Pathway{SMPDBID: "SMP00001", Name: "Coagulation", Category: "drug_action",
+		Drugs: []PathwayDrug{{"DB00001", "Lepirudin"}, {"DB00002", "Cetuximab"}}}
+	drugs := []*Drug{
+		{ID: "DB00001", Name: "Lepirudin", DrugRecordUpdatedOn: updated, Pathways: []Pathway{pathway},
+			Products: []Product{{Name: "Refludan", Labeller: "Bayer"}}},
+		{ID: "DB00002", Name: "Cetuximab", DrugRecordUpdatedOn: updated, Pathways: []Pathway{pathway},
+			Products: []Product{{Name: "Erbitux", Labeller: "ImClone"}}},
+		{ID: "DB00003", Name: "Dornase alfa", DrugRecordUpdatedOn: Date{Time: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)},
+			Products: []Product{{Name: "Pulmozyme", Labeller: "Genentech"}}},
+	}
+	if second {
+		drugs[0].Name = "Lepirudin recombinant"
+		drugs[1].Products[0].Labeller = "Eli Lilly"
+		drugs = drugs[:2]
+	}
+	return drugs
+}
+
+var mergeWriters = map[string]func(dir string) TableWriter{
+	"json": NewJSONWriter,
+	"csv":  NewCSVWriter,
+	"tsv":  NewTSVWriter,
+}
+
+// writeRelease writes the rows of drugs to dir as a Pipeline would
+func writeRelease(t *testing.T, dir, format string, drugs []*Drug) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0770); err != nil {
+		t.Fatal(err)
+	}
+	writer := mergeWriters[format](dir)
+	seen := map[string]map[string]bool{}
+	for _, d := range drugs {
+		for _, row := range unique(Rows(d), seen) {
+			if err := writer.Write(row.Table, row.Value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readLines returns the sorted lines of a file, or nil if it does not exist
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// merge merges the drugs of the second release updated since 2019 into base
+func merge(t *testing.T, base, output, format string) map[string]*TableUpdate {
+	t.Helper()
+	changes, err := NewChanges("2019-01-01", format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range releaseDrugs(true) {
+		changes.Add(d)
+	}
+	if changes.Updated() != 2 {
+		t.Errorf("%d drugs updated, want 2", changes.Updated())
+	}
+	updates, err := changes.Merge(base, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return updates
+}
+
+// checkMerged checks that every table merged into dir holds the rows
+// written for the whole second release to full
+func checkMerged(t *testing.T, dir, full, extension string) {
+	t.Helper()
+	for _, table := range Tables {
+		name := table.Name + extension
+		got, want := readLines(t, filepath.Join(dir, name)), readLines(t, filepath.Join(full, name))
+		if strings.Join(got, "") != strings.Join(want, "") {
+			t.Errorf("%s: merged\n%s\nwant\n%s", name, strings.Join(got, ""), strings.Join(want, ""))
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	for format := range mergeWriters {
+		t.Run(format, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "update")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			base, full := filepath.Join(dir, "base"), filepath.Join(dir, "full")
+			writeRelease(t, base, format, releaseDrugs(false))
+			writeRelease(t, full, format, releaseDrugs(true))
+			extension := recordFormats[format].extension
+
+			updates := merge(t, base, base, format)
+			checkMerged(t, base, full, extension)
+			for table, want := range map[string]TableChange{
+				"drugs":               {Added: 2, Removed: 3},
+				"drugs-products-join": {Added: 0, Removed: 1},
+				// the shared rows of the pathway do not change
+				"pathways":      {},
+				"pathway_drugs": {},
+				// products are not tied to a drug, but no drug sells these anymore
+				"products": {Added: 1, Removed: 2},
+			} {
+				update := updates[table]
+				if update == nil {
+					t.Errorf("%s not merged", table)
+					continue
+				}
+				if update.TableChange != want {
+					t.Errorf("%s: +%d -%d, want +%d -%d", table, update.Added, update.Removed, want.Added, want.Removed)
+				}
+			}
+			if update := updates["pathway_drugs"]; update != nil && update.Rows != 2 {
+				t.Errorf("%d pathway_drugs rows, want 2", update.Rows)
+			}
+
+			// merging the same release again changes nothing, and removes
+			// the duplicates left in the base
+			path := filepath.Join(base, "pathway_drugs"+extension)
+			var duplicate string
+			for _, line := range readLines(t, path) {
+				if strings.Contains(line, "DB00002") {
+					duplicate = line
+				}
+			}
+			file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := file.WriteString(duplicate); err != nil {
+				t.Fatal(err)
+			}
+			file.Close()
+			updates = merge(t, base, base, format)
+			checkMerged(t, base, full, extension)
+			for table, update := range updates {
+				want := TableChange{}
+				if table == "pathway_drugs" {
+					want.Removed = 1
+				}
+				if update.TableChange != want {
+					t.Errorf("merged again: %s: +%d -%d, want +%d -%d", table, update.Added, update.Removed, want.Added, want.Removed)
+				}
+			}
+		})
+	}
+}
+
+func TestNewChanges(t *testing.T) {
+	if _, err := NewChanges("2019-01-01", "parquet"); err == nil {
+		t.Error("parquet output merged")
+	}
+	if _, err := NewChanges("01/01/2019", "csv"); err == nil {
+		t.Error("invalid date accepted")
+	}
+	if _, err := NewChanges("", "csv"); err == nil {
+		t.Error("missing date accepted")
+	}
+}
+
+func TestChangesSince(t *testing.T) {
+	changes, err := NewChanges("2019-01-01", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		updated string
+		want    bool
+	}{
+		{"2018-12-31", false},
+		{"2019-01-01", true}, // the date of the last run is included
+		{"2019-01-02", true},
+		{"", true},
+	} {
+		date, err := ParseDate(test.updated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drug := &Drug{ID: "DB0000" + test.updated, Name: "Lepirudin", DrugRecordUpdatedOn: date,
+			Products: []Product{{Name: "Refludan"}}}
+		if updated := changes.Add(drug); updated != test.want {
+			t.Errorf("drug updated on %q merged: %v, want %v", test.updated, updated, test.want)
+		}
+	}
+	if changes.Updated() != 3 {
+		t.Errorf("%d drugs updated, want 3", changes.Updated())
+	}
+
+	// the rows collected are spilled to files, removed once merged
+	dir := changes.dir
+	if _, err := os.Stat(filepath.Join(dir, "shared.products")); err != nil {
+		t.Errorf("shared rows not spilled: %v", err)
+	}
+	output, err := ioutil.TempDir("", "update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(output)
+	if _, err := changes.Merge(filepath.Join(output, "base"), output); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("%s left after the merge: %v", dir, err)
+	}
+}
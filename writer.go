@@ -0,0 +1,253 @@
+package drugbank
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TableWriter streams the rows of the output tables to disk
+// as they are produced, instead of buffering whole tables in memory.
+type TableWriter interface {
+	// Write appends row to the named table
+	Write(table string, row interface{}) error
+	// Close flushes and closes every table
+	Close() error
+}
+
+// FileLister is implemented by the TableWriters that can list the files
+// they created, so that they can be told apart from other files of the
+// same directory
+type FileLister interface {
+	// Files returns the paths of the files created, in order
+	Files() []string
+}
+
+// encoder encodes a single row to an underlying writer
+type encoder interface {
+	Encode(row interface{}) error
+}
+
+// flusher is implemented by encoders buffering rows themselves
+type flusher interface {
+	Flush() error
+}
+
+// fastaRecord is implemented by rows holding a sequence,
+// which are written as FASTA records
+type fastaRecord interface {
+	FASTA() (header, sequence string)
+}
+
+// fastaEncoder writes sequences in FASTA format, 60 residues per line
+type fastaEncoder struct {
+	writer io.Writer
+}
+
+func (e *fastaEncoder) Encode(row interface{}) error {
+	header, sequence := row.(fastaRecord).FASTA()
+	if _, err := io.WriteString(e.writer, ">"+header+"\n"); err != nil {
+		return err
+	}
+	for len(sequence) > 0 {
+		n := 60
+		if len(sequence) < n {
+			n = len(sequence)
+		}
+		if _, err := io.WriteString(e.writer, sequence[:n]+"\n"); err != nil {
+			return err
+		}
+		sequence = sequence[n:]
+	}
+	return nil
+}
+
+// tableFile is an open output table
+type tableFile struct {
+	file    *os.File
+	buffer  *bufio.Writer
+	encoder encoder
+}
+
+// fileWriter writes each table to its own buffered file in a directory.
+// Files are created the first time a row is written to the table.
+// Tables of sequences are always written as FASTA files.
+type fileWriter struct {
+	dir        string
+	extension  string
+	newEncoder func(w io.Writer) encoder
+	tables     map[string]*tableFile
+	order      []string
+	files      []string // created so far
+}
+
+// NewJSONWriter returns a TableWriter writing every table as a
+// json-lines file named <table>.json in dir
+func NewJSONWriter(dir string) TableWriter {
+	return &fileWriter{
+		dir:       dir,
+		extension: ".json",
+		newEncoder: func(w io.Writer) encoder {
+			return json.NewEncoder(w)
+		},
+		tables: map[string]*tableFile{},
+	}
+}
+
+// NewCSVWriter returns a TableWriter writing every table as an RFC 4180
+// csv file named <table>.csv in dir. The header of each file lists the
+// columns of its rows (see Columns) in a stable order.
+func NewCSVWriter(dir string) TableWriter {
+	return &fileWriter{
+		dir:       dir,
+		extension: ".csv",
+		newEncoder: func(w io.Writer) encoder {
+			return &csvEncoder{writer: csv.NewWriter(w)}
+		},
+		tables: map[string]*tableFile{},
+	}
+}
+
+// NewTSVWriter returns a TableWriter writing every table as a tab
+// separated file named <table>.tsv in dir, in the text format read by
+// PostgreSQL's COPY: there is no header, columns follow the order
+// of Columns, empty dates are written as \N and tabs, line breaks
+// and backslashes are escaped.
+func NewTSVWriter(dir string) TableWriter {
+	return &fileWriter{
+		dir:       dir,
+		extension: ".tsv",
+		newEncoder: func(w io.Writer) encoder {
+			return &tsvEncoder{writer: w}
+		},
+		tables: map[string]*tableFile{},
+	}
+}
+
+// tsvEncoder writes rows as lines of tab separated values
+type tsvEncoder struct {
+	writer  io.Writer
+	columns []Column
+	line    []byte
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func (e *tsvEncoder) Encode(row interface{}) error {
+	if e.columns == nil {
+		e.columns = Columns(row)
+	}
+	e.line = e.line[:0]
+	for i, column := range e.columns {
+		if i > 0 {
+			e.line = append(e.line, '\t')
+		}
+		value := column.String(row)
+		if value == "" && column.Kind != KindString {
+			e.line = append(e.line, `\N`...)
+			continue
+		}
+		e.line = append(e.line, tsvEscaper.Replace(value)...)
+	}
+	e.line = append(e.line, '\n')
+	_, err := e.writer.Write(e.line)
+	return err
+}
+
+// csvEncoder writes rows as csv records, preceded by a header
+// built from the type of the first row
+type csvEncoder struct {
+	writer  *csv.Writer
+	columns []Column
+	record  []string
+}
+
+func (e *csvEncoder) Encode(row interface{}) error {
+	if e.columns == nil {
+		e.columns = Columns(row)
+		e.record = make([]string, len(e.columns))
+		for i, column := range e.columns {
+			e.record[i] = column.Name
+		}
+		if err := e.writer.Write(e.record); err != nil {
+			return err
+		}
+	}
+	for i, column := range e.columns {
+		e.record[i] = column.String(row)
+	}
+	return e.writer.Write(e.record)
+}
+
+func (e *csvEncoder) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (w *fileWriter) Write(table string, row interface{}) error {
+	t, ok := w.tables[table]
+	if !ok {
+		_, fasta := row.(fastaRecord)
+		extension := w.extension
+		if fasta {
+			extension = ".fasta"
+		}
+		file, err := os.Create(filepath.Join(w.dir, table+extension))
+		if err != nil {
+			return err
+		}
+		w.files = append(w.files, file.Name())
+		buffer := bufio.NewWriterSize(file, 64*1024)
+		t = &tableFile{file, buffer, w.newEncoder(buffer)}
+		if fasta {
+			t.encoder = &fastaEncoder{buffer}
+		}
+		w.tables[table] = t
+		w.order = append(w.order, table)
+	}
+	return t.encoder.Encode(row)
+}
+
+func (w *fileWriter) Files() []string {
+	return w.files
+}
+
+func (w *fileWriter) Close() error {
+	var firstErr error
+	for _, table := range w.order {
+		t := w.tables[table]
+		if f, ok := t.encoder.(flusher); ok {
+			if err := f.Flush(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		if err := t.buffer.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := t.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	w.tables = map[string]*tableFile{}
+	w.order = nil
+	return firstErr
+}
+
+// WriteFile creates the file at path with the given mode, or truncates it,
+// with the contents written by write
+func WriteFile(path string, mode os.FileMode, write func(io.Writer) error) (err error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	return write(file)
+}
@@ -0,0 +1,104 @@
+package drugbank
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// csvDrug holds values csv must quote: commas, quotes and line breaks
+func csvDrug() *Drug {
+	d := &Drug{ID: "DB00001", Name: "Lepirudin"}
+	d.References.Links = []Link{
+		{Title: `Refludan, "lepirudin" label`, URL: "https://www.accessdata.fda.gov/drugsatfda_docs/label/2006/020807s020lbl.pdf"},
+		{Title: `Refludan, "lepirudin" label`, URL: "https://example.com/duplicate"},
+	}
+	d.Packagers = []Packager{{Name: "Bayer Healthcare", URL: "http://www.bayer.com/products"}}
+	d.AffectedOrganisms = []Organism{{Description: " Humans and other mammals"}, {Description: "Humans and other mammals"}}
+	d.ExternalIdentifiers = []ExternalIdentifier{{Resource: "UniProtKB", Identifier: "P01050"}}
+	d.ExternalLinks = []ExternalLink{{Resource: "RxList", URL: "http://www.rxlist.com/cgi/generic/lepirudin.htm"}}
+	var price Price
+	price.Description = "Refludan 50 mg vial\nhospital"
+	price.Details.Amount = 273.5
+	price.Details.Currency = "USD"
+	price.Unit = "vial"
+	d.Prices = []Price{price}
+	return d
+}
+
+func TestCSVWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "writer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writer := NewCSVWriter(dir)
+	seen := map[string]map[string]bool{}
+	for _, row := range unique(Rows(csvDrug()), seen) {
+		if err := writer.Write(row.Table, row.Value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// headers list the columns in struct order, and the resource tables
+	// hold each resource once, by host, as json2csv.py wrote them
+	want := map[string]string{
+		"links": "drugbank-id,title,url\n" +
+			"DB00001,\"Refludan, \"\"lepirudin\"\" label\",https://www.accessdata.fda.gov/drugsatfda_docs/label/2006/020807s020lbl.pdf\n" +
+			"DB00001,\"Refludan, \"\"lepirudin\"\" label\",https://example.com/duplicate\n",
+		"links_resources":               "title,url\n\"Refludan, \"\"lepirudin\"\" label\",www.accessdata.fda.gov\n",
+		"packagers_resources":           "name,url\nBayer Healthcare,www.bayer.com\n",
+		"organisms_resources":           "organism\nHumans and other mammals\n",
+		"external_identifiers_resource": "resource\nUniProtKB\n",
+		"external_links_resources":      "resource,url\nRxList,www.rxlist.com\n",
+		"prices":                        "drugbank-id,description,cost,currency,sale-unit\nDB00001,\"Refludan 50 mg vial\nhospital\",273.5,USD,vial\n",
+	}
+	for table, want := range want {
+		data, err := ioutil.ReadFile(filepath.Join(dir, table+".csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s.csv:\n%s\nwant\n%s", table, data, want)
+		}
+	}
+}
+
+// TestFASTAWriter checks that sequences are wrapped at 60 residues
+func TestFASTAWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "writer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writer := NewCSVWriter(dir)
+	rows := []sequenceRow{
+		{"P00734", "F2", "Prothrombin", strings.Repeat("M", 60) + strings.Repeat("A", 60) + "HVR"},
+		{"P05164", "", "Myeloperoxidase", strings.Repeat("G", 60)},
+	}
+	for _, row := range rows {
+		if err := writer.Write("polypeptide_sequences", row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "polypeptide_sequences.fasta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ">P00734|F2 Prothrombin\n" +
+		strings.Repeat("M", 60) + "\n" +
+		strings.Repeat("A", 60) + "\n" +
+		"HVR\n" +
+		">P05164 Myeloperoxidase\n" +
+		strings.Repeat("G", 60) + "\n"
+	if string(data) != want {
+		t.Errorf("polypeptide_sequences.fasta:\n%s\nwant\n%s", data, want)
+	}
+}
@@ -0,0 +1,208 @@
+package drugbank
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Schema lists the element and attribute paths allowed by an xml schema,
+// named like the paths counted by Coverage. A path maps to true if the
+// schema lists all the elements and attributes it may hold, so that
+// others are unknown, and to false if they are not checked.
+type Schema map[string]bool
+
+// drugElements are the children of the drug element in drugbank.xsd
+var drugElements = []string{
+	"@type", "@created", "@updated",
+	"drugbank-id", "name", "description", "cas-number", "unii", "average-mass",
+	"monoisotopic-mass", "state", "groups", "general-references", "synthesis-reference",
+	"indication", "pharmacodynamics", "mechanism-of-action", "toxicity", "metabolism",
+	"absorption", "half-life", "protein-binding", "route-of-elimination",
+	"volume-of-distribution", "clearance", "classification", "salts", "synonyms",
+	"products", "international-brands", "mixtures", "packagers", "manufacturers",
+	"prices", "categories", "affected-organisms", "dosages", "atc-codes", "ahfs-codes",
+	"pdb-entries", "fda-label", "msds", "patents", "food-interactions",
+	"drug-interactions", "sequences", "experimental-properties", "calculated-properties",
+	"external-identifiers", "external-links", "pathways", "reactions", "snp-effects",
+	"snp-adverse-drug-reactions", "targets", "enzymes", "carriers", "transporters",
+}
+
+// BuiltinSchema returns the rules checked without a schema definition:
+// the elements and attributes of drugs, as defined by drugbank.xsd.
+// What they hold is not checked.
+func BuiltinSchema() Schema {
+	schema := Schema{"drugbank": false, "drugbank/drug": true}
+	for _, element := range drugElements {
+		schema["drugbank/drug/"+element] = false
+	}
+	return schema
+}
+
+// Unknown returns the paths not allowed by the schema, leaving out the
+// paths held by an unknown element
+func (s Schema) Unknown(paths []PathCount) []PathCount {
+	unknown := []PathCount{}
+	for _, pc := range paths {
+		if !s.known(pc.Path) && s[parent(pc.Path)] {
+			unknown = append(unknown, pc)
+		}
+	}
+	return unknown
+}
+
+// Unmatched returns the paths bound by the xml tags of Drug that the
+// schema does not allow, which are most likely misspelled
+func (s Schema) Unmatched() []string {
+	unmatched := []string{}
+	for _, path := range drugBinding.paths("drugbank/drug", nil) {
+		if !s.known(path) && s[parent(path)] {
+			unmatched = append(unmatched, path)
+		}
+	}
+	sort.Strings(unmatched)
+	return unmatched
+}
+
+func (s Schema) known(path string) bool {
+	_, ok := s[path]
+	return ok
+}
+
+// parent returns the path of the element holding path
+func parent(path string) string {
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// xsdNode is an element of an xml schema document
+type xsdNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []xsdNode  `xml:",any"`
+}
+
+func (n *xsdNode) attr(name string) string {
+	for _, attr := range n.Attrs {
+		if attr.Name.Local == name {
+			// drop the namespace prefix of type and element references
+			value := attr.Value
+			if i := strings.IndexByte(value, ':'); i >= 0 && name != "name" {
+				value = value[i+1:]
+			}
+			return value
+		}
+	}
+	return ""
+}
+
+// ReadXSD reads the paths allowed by an xml schema definition, such as the
+// drugbank.xsd published by DrugBank, from the global elements down.
+// Named and anonymous types, type extensions, groups and attribute groups
+// are followed; recursive types are only expanded once on a path.
+func ReadXSD(r io.Reader) (Schema, error) {
+	var root xsdNode
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, err
+	}
+	if root.XMLName.Local != "schema" {
+		return nil, errors.New("not an xml schema definition")
+	}
+	s := &xsdExpander{
+		schema:          Schema{},
+		elements:        map[string]*xsdNode{},
+		types:           map[string]*xsdNode{},
+		groups:          map[string]*xsdNode{},
+		attributeGroups: map[string]*xsdNode{},
+		expanding:       map[string]bool{},
+	}
+	for i := range root.Children {
+		child := &root.Children[i]
+		name := child.attr("name")
+		switch child.XMLName.Local {
+		case "element":
+			s.elements[name] = child
+		case "complexType":
+			s.types[name] = child
+		case "group":
+			s.groups[name] = child
+		case "attributeGroup":
+			s.attributeGroups[name] = child
+		}
+	}
+	for name, element := range s.elements {
+		s.element(element, name)
+	}
+	return s.schema, nil
+}
+
+// xsdExpander expands the definitions of a schema to paths
+type xsdExpander struct {
+	schema          Schema
+	elements        map[string]*xsdNode // global, by name
+	types           map[string]*xsdNode // complex types, by name
+	groups          map[string]*xsdNode
+	attributeGroups map[string]*xsdNode
+	expanding       map[string]bool // types being expanded
+}
+
+func (s *xsdExpander) element(element *xsdNode, path string) {
+	s.schema[path] = true // every element of the schema is fully defined
+	if t, ok := s.types[element.attr("type")]; ok {
+		s.namedType(element.attr("type"), t, path)
+	}
+	s.content(element, path)
+}
+
+func (s *xsdExpander) namedType(name string, t *xsdNode, path string) {
+	if s.expanding[name] {
+		return
+	}
+	s.expanding[name] = true
+	defer delete(s.expanding, name)
+	s.content(t, path)
+}
+
+// content adds the elements and attributes defined by the children of n
+func (s *xsdExpander) content(n *xsdNode, path string) {
+	for i := range n.Children {
+		child := &n.Children[i]
+		switch child.XMLName.Local {
+		case "element":
+			if ref := child.attr("ref"); ref != "" {
+				if element, ok := s.elements[ref]; ok {
+					s.element(element, path+"/"+ref)
+				}
+				continue
+			}
+			s.element(child, path+"/"+child.attr("name"))
+		case "attribute":
+			name := child.attr("name")
+			if name == "" {
+				name = child.attr("ref")
+			}
+			s.schema[path+"/@"+name] = false
+		case "group":
+			if group, ok := s.groups[child.attr("ref")]; ok {
+				s.namedType("group "+child.attr("ref"), group, path)
+			}
+			s.content(child, path)
+		case "attributeGroup":
+			if group, ok := s.attributeGroups[child.attr("ref")]; ok {
+				s.content(group, path)
+			}
+			s.content(child, path)
+		case "extension", "restriction":
+			if t, ok := s.types[child.attr("base")]; ok {
+				s.namedType(child.attr("base"), t, path)
+			}
+			s.content(child, path)
+		case "complexType", "complexContent", "simpleContent", "sequence", "choice", "all":
+			s.content(child, path)
+		}
+	}
+}
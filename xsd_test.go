@@ -0,0 +1,122 @@
+package drugbank
+
+import (
+	"strings"
+	"testing"
+)
+
+const testXSD = `<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns="http://www.drugbank.ca" targetNamespace="http://www.drugbank.ca">
+  <xs:element name="drugbank" type="drugbank-type"/>
+  <xs:complexType name="drugbank-type">
+    <xs:sequence>
+      <xs:element name="drug" type="drug-type" maxOccurs="unbounded"/>
+    </xs:sequence>
+    <xs:attributeGroup ref="release"/>
+  </xs:complexType>
+  <xs:attributeGroup name="release">
+    <xs:attribute name="version" type="xs:string"/>
+  </xs:attributeGroup>
+  <xs:complexType name="entity-type">
+    <xs:sequence>
+      <xs:element name="name" type="xs:string"/>
+    </xs:sequence>
+    <xs:attribute name="created" type="xs:date"/>
+  </xs:complexType>
+  <xs:complexType name="drug-type">
+    <xs:complexContent>
+      <xs:extension base="entity-type">
+        <xs:sequence>
+          <xs:element ref="description"/>
+          <xs:group ref="properties"/>
+          <xs:element name="classification">
+            <xs:complexType>
+              <xs:choice>
+                <xs:element name="kingdom" type="xs:string"/>
+              </xs:choice>
+            </xs:complexType>
+          </xs:element>
+          <xs:element name="mixture" type="drug-type" minOccurs="0"/>
+        </xs:sequence>
+        <xs:attribute name="type" type="xs:string"/>
+      </xs:extension>
+    </xs:complexContent>
+  </xs:complexType>
+  <xs:group name="properties">
+    <xs:sequence>
+      <xs:element name="state" type="xs:string"/>
+    </xs:sequence>
+  </xs:group>
+  <xs:element name="description" type="xs:string"/>
+</xs:schema>`
+
+func TestReadXSD(t *testing.T) {
+	schema, err := ReadXSD(strings.NewReader(testXSD))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		"drugbank/@version",                    // attribute group
+		"drugbank/drug/name",                   // base type
+		"drugbank/drug/@created",               // base type attribute
+		"drugbank/drug/@type",                  // extension attribute
+		"drugbank/drug/description",            // element reference
+		"drugbank/drug/state",                  // group
+		"drugbank/drug/classification/kingdom", // anonymous type
+		"drugbank/drug/mixture",                // recursive type
+		"description",                          // global element
+	} {
+		if !schema.known(path) {
+			t.Errorf("%s not allowed", path)
+		}
+	}
+	// recursive types are expanded once on a path
+	if schema.known("drugbank/drug/mixture/name") {
+		t.Error("drug-type expanded within itself")
+	}
+
+	unknown := schema.Unknown([]PathCount{
+		{Path: "drugbank/drug/name"},
+		{Path: "drugbank/drug/@updated"},
+		{Path: "drugbank/drug/trivia"},
+		{Path: "drugbank/drug/trivia/fact"},
+	})
+	if len(unknown) != 2 || unknown[0].Path != "drugbank/drug/@updated" || unknown[1].Path != "drugbank/drug/trivia" {
+		t.Errorf("unknown %+v, want @updated and trivia", unknown)
+	}
+
+	// Drug binds drugbank-id, which this schema does not allow
+	if unmatched := strings.Join(schema.Unmatched(), " "); !strings.Contains(unmatched, "drugbank/drug/drugbank-id") {
+		t.Errorf("unmatched %s, want drugbank-id", unmatched)
+	}
+
+	if _, err := ReadXSD(strings.NewReader("<drugbank/>")); err == nil {
+		t.Error("document read as a schema")
+	}
+}
+
+// unboundElements are the drug elements Drug leaves out on purpose
+var unboundElements = map[string]bool{"average-mass": true, "monoisotopic-mass": true}
+
+// TestBuiltinSchema checks that the xml tags of Drug and the elements
+// of drugbank.xsd agree, so that a misspelled tag fails here
+func TestBuiltinSchema(t *testing.T) {
+	schema := BuiltinSchema()
+	if unmatched := schema.Unmatched(); len(unmatched) > 0 {
+		t.Errorf("Drug binds %v, which drugbank.xsd does not define", unmatched)
+	}
+	for _, element := range drugElements {
+		var bound bool
+		if strings.HasPrefix(element, "@") {
+			bound = drugBinding.attribute(element[1:])
+		} else {
+			bound = drugBinding.element(element) != nil
+		}
+		if bound == unboundElements[element] {
+			t.Errorf("drugbank/drug/%s bound: %v", element, bound)
+		}
+	}
+	if unknown := schema.Unknown([]PathCount{{Path: "drugbank/drug/products/product/trivia"}}); len(unknown) > 0 {
+		t.Errorf("unknown %v, want what drug elements hold left unchecked", unknown)
+	}
+}